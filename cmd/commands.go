@@ -1,24 +1,49 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/vbrdnk/tmx/internal/path"
 	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/daemon"
 	"github.com/vbrdnk/tmx/pkg/discovery"
+	"github.com/vbrdnk/tmx/pkg/git"
 	"github.com/vbrdnk/tmx/pkg/history"
+	"github.com/vbrdnk/tmx/pkg/index"
 	"github.com/vbrdnk/tmx/pkg/session"
 	"github.com/vbrdnk/tmx/pkg/ui"
 
 	"github.com/urfave/cli/v3"
 )
 
-func DefaultAction(targetDir string, config *config.Config, cliDepth int, sessionManager *session.SessionManager) error {
+func DefaultAction(targetDir string, cfg *config.Config, cliDepth int, cliMode string, sessionManager *session.SessionManager, client *daemon.Client) error {
+	// The CLI search mode takes precedence over the configured one
+	cfg, err := cfg.WithSearchMode(cliMode)
+	if err != nil {
+		return err
+	}
+
 	// Use DirectorySelector to find and select directory
-	selector := discovery.NewDirectorySelector(config)
+	selector := discovery.NewDirectorySelector(cfg)
+
+	if client != nil {
+		err := daemonSelect(selector, client, targetDir, cliDepth, cliMode, sessionManager)
+		if !errors.Is(err, daemon.ErrUnavailable) {
+			return err
+		}
+		// The daemon went away; carry on without it
+	}
+
 	workDir, err := selector.SelectDirectory(targetDir, cliDepth)
 	if err != nil {
 		color.Red(err.Error())
@@ -28,27 +53,435 @@ func DefaultAction(targetDir string, config *config.Config, cliDepth int, sessio
 	// Use SessionManager to resolve and attach to session
 	if err := sessionManager.ResolveSession(workDir); err != nil {
 		color.Red("Error resolving session: %v", err)
+		printTmuxHint(err)
+	}
+
+	// Let a background index refresh finish so the next run sees fresh results
+	selector.WaitForRefresh()
+	return nil
+}
+
+// daemonSelect lets the user pick from the directory list served by the daemon, then
+// creates the session, if needed, and attaches to it
+func daemonSelect(selector *discovery.DirectorySelector, client *daemon.Client, targetDir string, cliDepth int, cliMode string, sessionManager *session.SessionManager) error {
+	list, err := client.Search(targetDir, cliDepth, cliMode)
+	if err != nil {
+		if errors.Is(err, daemon.ErrUnavailable) {
+			return err
+		}
+		color.Red(err.Error())
+		return nil
+	}
+
+	workDir, err := selector.SelectFromList(list)
+	if err != nil {
+		color.Red(err.Error())
+		return nil
+	}
+
+	if err := sessionManager.ResolveSession(workDir); err != nil {
+		color.Red("Error resolving session: %v", err)
+		printTmuxHint(err)
+	}
+	return nil
+}
+
+func IndexRebuildAction(_ctx context.Context, cmd *cli.Command, cfg *config.Config) error {
+	targetDir, err := path.GetWorkingDirPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	if cfg != nil && !cfg.GetIndexCache() {
+		color.Yellow("Note: index_cache is disabled in your config, so the index will not be used.")
+	}
+
+	entries, err := discovery.NewDirectorySelector(cfg).RebuildIndex(targetDir, int(cmd.Int("depth")))
+	for _, entry := range entries {
+		color.Green("Indexed %d directories under %s", len(entry.Dirs), entry.Root)
+	}
+	if err != nil {
+		color.Red("Error rebuilding index: %v", err)
+	}
+
+	return nil
+}
+
+func IndexClearAction(_ctx context.Context, _cmd *cli.Command) error {
+	cache, err := index.NewCache()
+	if err != nil {
+		color.Red("Error locating index cache: %v", err)
+		return nil
+	}
+
+	if err := cache.Clear(); err != nil {
+		color.Red("Error clearing index cache: %v", err)
+		return nil
+	}
+
+	color.Green("Index cache cleared.")
+	return nil
+}
+
+func WorktreeAction(_ctx context.Context, cmd *cli.Command, sessionManager *session.SessionManager) error {
+	repoDir, err := worktreeRepoDir(cmd.Args().First())
+	if err != nil {
+		return err
+	}
+
+	worktrees, err := git.ListWorktrees(repoDir)
+	if err != nil {
+		color.Red("Error listing worktrees: %v", err)
+		return nil
 	}
+
+	var lines []string
+	for _, wt := range worktrees {
+		// A bare repository has no working files to open a session in
+		if wt.Bare {
+			continue
+		}
+		lines = append(lines, worktreeLine(wt))
+	}
+
+	selected, err := ui.FuzzyFind([]byte(strings.Join(lines, "\n")))
+	if err != nil {
+		if errors.Is(err, ui.ErrNoSelection) {
+			color.Yellow("No worktree selected, exiting.")
+			os.Exit(0)
+		}
+		return err
+	}
+
+	// Lines are "<branch> <path>"; branch names never contain spaces
+	_, dir, _ := strings.Cut(strings.TrimSpace(selected), " ")
+	if err := sessionManager.ResolveWorktreeSession(dir); err != nil {
+		color.Red("Error resolving session: %v", err)
+		printTmuxHint(err)
+	}
+
+	return nil
+}
+
+func WorktreeAddAction(_ctx context.Context, cmd *cli.Command, sessionManager *session.SessionManager) error {
+	branch := cmd.Args().First()
+	if branch == "" {
+		return errors.New("missing branch name: tmx worktree add <branch>")
+	}
+
+	repoDir, err := worktreeRepoDir(cmd.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	worktrees, err := git.ListWorktrees(repoDir)
+	if err != nil {
+		color.Red("Error listing worktrees: %v", err)
+		return nil
+	}
+
+	// Reuse the worktree if the branch is already checked out somewhere
+	for _, wt := range worktrees {
+		if wt.Branch == branch {
+			color.Yellow("Branch %s is already checked out in %s", branch, wt.Path)
+			if err := sessionManager.ResolveWorktreeSession(wt.Path); err != nil {
+				color.Red("Error resolving session: %v", err)
+				printTmuxHint(err)
+			}
+			return nil
+		}
+	}
+
+	wtPath := cmd.String("path")
+	if wtPath == "" {
+		// The first worktree listed is always the main one
+		wtPath = git.DefaultWorktreePath(strings.TrimSuffix(worktrees[0].Path, ".git"), branch)
+	}
+
+	color.Green("Creating worktree for %s in %s", branch, wtPath)
+	if err := git.AddWorktree(repoDir, branch, wtPath); err != nil {
+		color.Red("Error creating worktree: %v", err)
+		return nil
+	}
+
+	if err := sessionManager.ResolveWorktreeSession(wtPath); err != nil {
+		color.Red("Error resolving session: %v", err)
+		printTmuxHint(err)
+	}
+
+	return nil
+}
+
+// worktreeRepoDir returns the directory whose repository the worktree commands operate
+// on: the given path, or the current directory
+func worktreeRepoDir(arg string) (string, error) {
+	dir := arg
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := git.Inspect(dir); err != nil {
+		absDir, _ := filepath.Abs(dir)
+		return "", fmt.Errorf("%s is not inside a git repository", absDir)
+	}
+	return dir, nil
+}
+
+// worktreeLine formats a worktree for the picker as "<branch> <path>"
+func worktreeLine(wt git.Worktree) string {
+	branch := wt.Branch
+	if branch == "" && len(wt.Head) >= 7 {
+		branch = "detached@" + wt.Head[:7]
+	}
+	return branch + " " + wt.Path
+}
+
+func ConfigCheckAction(_ctx context.Context, cmd *cli.Command) error {
+	result, err := config.Check(cmd.String("profile"))
+	if err != nil {
+		return err
+	}
+
+	printConfigErrors(result.Errors)
+	for _, w := range result.Warnings {
+		color.Yellow("%s: warning: %v", w.Location(), w.Error)
+	}
+
+	failed := len(result.Errors) > 0 || (cmd.Bool("strict") && len(result.Warnings) > 0)
+	summary := fmt.Sprintf("Checked %d config files in %s: %d errors, %d warnings", len(result.Files), result.Dir, len(result.Errors), len(result.Warnings))
+	if failed {
+		return errors.New(summary)
+	}
+
+	color.Green(summary)
 	return nil
 }
 
-func ListSessionsAction(_ctx context.Context, _cmd *cli.Command, sessionManager *session.SessionManager) error {
+func ConfigInitAction(_ctx context.Context, cmd *cli.Command) error {
+	file, err := config.InitConfig(cmd.Bool("force"))
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", file)
+	}
+	if err != nil {
+		return err
+	}
+
+	color.Green("Wrote starter config to %s", file)
+	return nil
+}
+
+func ConfigWatchAction(ctx context.Context, cmd *cli.Command) error {
+	watcher, err := config.NewWatcher(cmd.String("profile"))
+	if err != nil {
+		return fmt.Errorf("failed to watch the configuration: %w", err)
+	}
+	defer watcher.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	printConfigErrors(watcher.Errors())
+	color.Green("Watching the configuration for changes, press Ctrl-C to stop")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case reload := <-watcher.Reloads():
+			printConfigErrors(reload.Errors)
+			switch {
+			case reload.Applied:
+				color.Green("%s Reloaded the configuration", time.Now().Format(time.TimeOnly))
+			default:
+				color.Yellow("%s Kept the last good configuration", time.Now().Format(time.TimeOnly))
+			}
+		}
+	}
+}
+
+// printConfigErrors prints configuration errors as "file:line:column: error: message"
+func printConfigErrors(errors []config.ConfigError) {
+	for _, e := range errors {
+		color.Red("%s: error: %v", e.Location(), e.Error)
+	}
+}
+
+func ConfigSchemaAction(_ctx context.Context, _cmd *cli.Command) error {
+	_, err := os.Stdout.Write(config.Schema)
+	return err
+}
+
+func DaemonAction(ctx context.Context, cmd *cli.Command, tmux session.Server) error {
+	socket, err := daemon.SocketPath()
+	if err != nil {
+		return fmt.Errorf("failed to locate the daemon socket: %w", err)
+	}
+
+	profile := daemonProfile(cmd)
+	watcher, err := config.NewWatcher(profile)
+	if err != nil {
+		return fmt.Errorf("failed to watch the configuration: %w", err)
+	}
+	defer watcher.Close()
+	printConfigErrors(watcher.Errors())
+
+	server, err := daemon.NewServer(socket, watcher, profile, tmux)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Close() //nolint:errcheck
+	}()
+
+	color.Green("Daemon listening on %s, press Ctrl-C to stop", socket)
+	return server.Serve()
+}
+
+func DaemonStatusAction(_ctx context.Context, _cmd *cli.Command) error {
+	client, err := daemon.Connect()
+	if err != nil {
+		return err
+	}
+
+	status, err := client.Status()
+	if errors.Is(err, daemon.ErrUnavailable) {
+		color.Yellow("The daemon is not running")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	color.Green("The daemon is running with pid %d, profile %s, tmux server %s", status.Pid, cmp.Or(status.Profile, "(default)"), status.Server)
+	return nil
+}
+
+func DaemonStopAction(_ctx context.Context, _cmd *cli.Command) error {
+	client, err := daemon.Connect()
+	if err != nil {
+		return err
+	}
+
+	err = client.Shutdown()
+	if errors.Is(err, daemon.ErrUnavailable) {
+		color.Yellow("The daemon is not running")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stop the daemon: %w", err)
+	}
+	color.Green("Daemon stopped")
+	return nil
+}
+
+func WorkspaceAddAction(_ctx context.Context, cmd *cli.Command) error {
+	dir := cmd.Args().First()
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("invalid directory: %s", dir)
+	}
+
+	ws := config.InferWorkspace(dir)
+	if name := cmd.String("name"); name != "" {
+		ws.Name = name
+	}
+
+	if !cmd.Bool("yes") {
+		if ws, err = promptWorkspace(ui.NewPrompter(os.Stdin, os.Stdout), ws); err != nil {
+			return err
+		}
+	}
+
+	file, err := config.AddWorkspace(ws)
+	if err != nil {
+		return err
+	}
+
+	color.Green("Added workspace %q to %s", ws.Name, file)
+	return nil
+}
+
+// promptWorkspace asks for the name and windows of ws, offering the inferred ones as defaults
+func promptWorkspace(p *ui.Prompter, ws config.WorkspaceConfig) (config.WorkspaceConfig, error) {
+	var err error
+	if ws.Name, err = p.Ask("Workspace name", ws.Name); err != nil {
+		return ws, err
+	}
+
+	fmt.Println("Windows:")
+	for _, w := range ws.Windows {
+		fmt.Printf("  %s: %s\n", w.Name, cmp.Or(w.Command, "(shell)"))
+	}
+	keep, err := p.Confirm("Use these windows?", true)
+	if err != nil || keep {
+		return ws, err
+	}
+
+	ws.Windows = nil
+	for {
+		name, err := p.Ask("Window name (empty to finish)", "")
+		if err != nil || name == "" {
+			return ws, err
+		}
+		command, err := p.Ask(fmt.Sprintf("Command for %s (empty for a shell)", name), "")
+		if err != nil {
+			return ws, err
+		}
+		ws.Windows = append(ws.Windows, config.WindowConfig{Name: name, Command: command})
+	}
+}
+
+func ListSessionsAction(_ctx context.Context, _cmd *cli.Command, sessionManager *session.SessionManager, client *daemon.Client) error {
+	if client != nil {
+		sessions, err := client.List()
+		if err == nil {
+			if len(sessions) == 0 {
+				color.Yellow("No active tmux sessions")
+			}
+			for _, line := range sessions {
+				fmt.Println(line)
+			}
+			return nil
+		}
+		if !errors.Is(err, daemon.ErrUnavailable) {
+			color.Red("Error getting sessions list: %v", err)
+			printTmuxHint(err)
+			return nil
+		}
+	}
+
 	if err := sessionManager.ListSessions(); err != nil {
-		color.Red("Error getting sessions list")
+		if errors.Is(err, session.ErrNoServer) {
+			color.Yellow("No active tmux sessions")
+			return nil
+		}
+		color.Red("Error getting sessions list: %v", err)
 	}
 
 	return nil
 }
 
-func resolveSession(cmd *cli.Command) (string, error) {
+func resolveSession(cmd *cli.Command, sessionManager *session.SessionManager) (string, error) {
 	if arg := cmd.Args().First(); arg != "" {
 		return arg, nil
 	}
-	return selectFromActiveSessions()
+	return selectFromActiveSessions(sessionManager)
 }
 
 func AttachToSessionAction(_ctx context.Context, cmd *cli.Command, sessionManager *session.SessionManager) error {
-	sess, err := resolveSession(cmd)
+	sess, err := resolveSession(cmd, sessionManager)
 	if err != nil {
 		color.Red("Error selecting active session: %v", err)
 		return nil
@@ -56,6 +489,7 @@ func AttachToSessionAction(_ctx context.Context, cmd *cli.Command, sessionManage
 
 	if err := sessionManager.AttachToSession(sess); err != nil {
 		color.Red("Error connecting to %s tmux session: %v", sess, err)
+		printTmuxHint(err)
 	}
 
 	return nil
@@ -84,33 +518,74 @@ func RecentSessionAction(_ctx context.Context, _cmd *cli.Command, cfg *config.Co
 
 	if err := sessionManager.AttachToSession(strings.TrimSpace(selected)); err != nil {
 		color.Red("Error connecting to %s tmux session: %v", selected, err)
+		printTmuxHint(err)
 	}
 
 	return nil
 }
 
-func KillSessionAction(_ctx context.Context, cmd *cli.Command, sessionManager *session.SessionManager) error {
-	sess, err := resolveSession(cmd)
+func KillSessionAction(_ctx context.Context, cmd *cli.Command, sessionManager *session.SessionManager, client *daemon.Client) error {
+	sess, err := resolveSession(cmd, sessionManager)
 	if err != nil {
 		color.Red("Error selecting active session: %v", err)
 		return nil
 	}
 
-	if err := sessionManager.KillSession(sess); err != nil {
+	if client != nil {
+		err = client.Kill(sess)
+	}
+	if client == nil || errors.Is(err, daemon.ErrUnavailable) {
+		err = sessionManager.KillSession(sess)
+	}
+	if err != nil {
 		color.Red("Error killing %s tmux session: %v", sess, err)
+		printTmuxHint(err)
 	}
 
 	return nil
 }
 
-func selectFromActiveSessions() (string, error) {
-	cmd := session.NewTmuxCommand("list-sessions")
-	cmdOutput, err := cmd.Output()
+func RenameSessionAction(_ctx context.Context, cmd *cli.Command, sessionManager *session.SessionManager) error {
+	sess, err := resolveSession(cmd, sessionManager)
+	if err != nil {
+		color.Red("Error selecting active session: %v", err)
+		return nil
+	}
+
+	newName := cmd.Args().Get(1)
+	if newName == "" {
+		newName, err = ui.NewPrompter(os.Stdin, os.Stdout).Ask(fmt.Sprintf("New name for %s", sess), "")
+		if err != nil {
+			return err
+		}
+	}
+
+	renamed, err := sessionManager.RenameSession(sess, newName)
 	if err != nil {
+		color.Red("Error renaming %s tmux session: %v", sess, err)
+		if !errors.Is(err, session.ErrDuplicateSession) {
+			printTmuxHint(err)
+		}
+		return nil
+	}
+
+	color.Green("Renamed session %s to %s", sess, renamed)
+	if ws := sessionManager.SessionWorkspace(sess); ws != "" {
+		color.Yellow("Session %s belongs to workspace %q; opening its directory again starts a new session named %s", sess, ws, sess)
+	}
+	return nil
+}
+
+func selectFromActiveSessions(sessionManager *session.SessionManager) (string, error) {
+	sessions, err := sessionManager.Sessions()
+	if errors.Is(err, session.ErrNoServer) {
 		return "", errors.New("no active tmux sessions")
 	}
+	if err != nil {
+		return "", err
+	}
 
-	if fullSessionName, err := ui.FuzzyFind(cmdOutput); err != nil {
+	if fullSessionName, err := ui.FuzzyFind([]byte(strings.Join(sessions, "\n"))); err != nil {
 		if errors.Is(err, ui.ErrNoSelection) {
 			color.Yellow("No sesison selected, exiting.")
 			os.Exit(0)
@@ -121,3 +596,17 @@ func selectFromActiveSessions() (string, error) {
 		return strings.Split(fullSessionName, ":")[0], nil
 	}
 }
+
+// printTmuxHint tells how to deal with the tmux errors tmx knows about
+func printTmuxHint(err error) {
+	switch {
+	case errors.Is(err, session.ErrNoServer):
+		color.Yellow("No tmux server is running. Run `tmx` to pick a directory and start a session")
+	case errors.Is(err, session.ErrSessionNotFound):
+		color.Yellow("Run `tmx list` to see the active sessions, or `tmx recent` to reopen a recent one")
+	case errors.Is(err, session.ErrDuplicateSession):
+		color.Yellow("A session with this name already exists; connect to it with `tmx connect`")
+	case errors.Is(err, session.ErrNestedSession):
+		color.Yellow("tmx is running inside tmux; unset $TMUX to attach in a nested client, or run tmx outside tmux")
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"log"
 	"os"
@@ -8,36 +9,82 @@ import (
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v3"
 	"github.com/vbrdnk/tmx/internal/path"
-	config "github.com/vbrdnk/tmx/pkg/config"
+	configpkg "github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/daemon"
 	"github.com/vbrdnk/tmx/pkg/session"
 )
 
 var Version = "dev" // will be overridden at build time with ldflags
 
 func Run() {
-	config, configErrors := config.ParseConfig()
-	if len(configErrors) > 0 {
-		for _, err := range configErrors {
-			log.Printf("Configuration error: %v", err)
-		}
-		// Continue execution even if there are config errors
-	}
-
-	// Create session manager instance
-	sessionManager := session.NewSessionManager(config)
+	// The config is loaded once the --profile flag has been parsed
+	var config *configpkg.Config
+	var sessionManager *session.SessionManager
+	// Client for a running daemon, nil when working without one
+	var client *daemon.Client
 
 	app := &cli.Command{
 		Name:                  "tmux sessionizer",
 		Description:           "Tmux session manager",
 		Version:               Version,
 		EnableShellCompletion: true,
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			var configErrors []configpkg.ConfigError
+			config, configErrors = configpkg.ParseConfigProfile(cmd.String("profile"))
+
+			// Create session manager instance
+			sessionManager = session.NewSessionManager(config)
+			sessionManager.SetServer(tmuxServer(cmd))
+			sessionManager.SetKeepOnError(cmd.Bool("keep-on-error"))
+
+			if useDaemon(cmd) {
+				client = connectDaemon(daemonProfile(cmd), sessionManager.Server())
+			}
+
+			// `tmx config` reports configuration problems itself
+			if len(configErrors) > 0 && cmd.Args().First() != "config" {
+				for _, err := range configErrors {
+					log.Printf("Configuration error: %s", err)
+				}
+				log.Printf("Run `tmx config check` for details")
+				// Continue execution even if there are config errors
+			}
+			return ctx, nil
+		},
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "config profile to apply (default: $TMX_PROFILE, then the hostname)",
+			},
+			&cli.StringFlag{
+				Name:    "socket-name",
+				Aliases: []string{"L"},
+				Usage:   "tmux server socket name, as with tmux -L",
+			},
+			&cli.StringFlag{
+				Name:    "socket-path",
+				Aliases: []string{"S"},
+				Usage:   "tmux server socket path, as with tmux -S (takes precedence over --socket-name)",
+			},
+			&cli.BoolFlag{
+				Name:  "keep-on-error",
+				Usage: "keep a session whose creation failed half-way instead of killing it, to inspect it",
+			},
+			&cli.BoolFlag{
+				Name:  "no-daemon",
+				Usage: "do not use a running daemon, even if one is available",
+			},
 			&cli.IntFlag{
 				Name:    "depth",
 				Aliases: []string{"d"},
 				Usage:   "search depth for nested directories (0 = unlimited)",
 				Value:   0, // 0 means use config default
 			},
+			&cli.StringFlag{
+				Name:    "mode",
+				Aliases: []string{"m"},
+				Usage:   "search mode: \"directory\" lists every directory, \"project\" lists only project roots",
+			},
 		},
 		Action: func(_ctx context.Context, cmd *cli.Command) error {
 			targetDirPath, err := path.GetWorkingDirPath(cmd)
@@ -46,7 +93,8 @@ func Run() {
 			}
 
 			depth := int(cmd.Int("depth"))
-			return DefaultAction(targetDirPath, config, depth, sessionManager)
+			mode := cmd.String("mode")
+			return DefaultAction(targetDirPath, config, depth, mode, sessionManager, client)
 		},
 		Commands: []*cli.Command{
 			{
@@ -54,7 +102,7 @@ func Run() {
 				Aliases: []string{"l", "ls"},
 				Usage:   "list currently active tmux sessions",
 				Action: func(_ctx context.Context, _cmd *cli.Command) error {
-					return ListSessionsAction(_ctx, _cmd, sessionManager)
+					return ListSessionsAction(_ctx, _cmd, sessionManager, client)
 				},
 			},
 			{
@@ -80,7 +128,167 @@ func Run() {
 				Usage:     "kill tmux session",
 				ArgsUsage: "[session]",
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					return KillSessionAction(ctx, cmd, sessionManager)
+					return KillSessionAction(ctx, cmd, sessionManager, client)
+				},
+			},
+			{
+				Name:      "rename",
+				Aliases:   []string{"mv"},
+				Usage:     "rename a tmux session, keeping its place in the recent sessions",
+				ArgsUsage: "[session] [new-name]",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return RenameSessionAction(ctx, cmd, sessionManager)
+				},
+			},
+			{
+				Name:      "worktree",
+				Aliases:   []string{"wt"},
+				Usage:     "open a session on one of the git worktrees of a repository",
+				ArgsUsage: "[path]",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return WorktreeAction(ctx, cmd, sessionManager)
+				},
+				Commands: []*cli.Command{
+					{
+						Name:      "add",
+						Usage:     "create a worktree for a branch and open a session on it",
+						ArgsUsage: "<branch> [repository]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "path",
+								Aliases: []string{"p"},
+								Usage:   "where to create the worktree (default: <repo>-<branch> next to the main worktree)",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return WorktreeAddAction(ctx, cmd, sessionManager)
+						},
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "inspect and set up the configuration",
+				Commands: []*cli.Command{
+					{
+						Name:  "init",
+						Usage: "write a commented starter tmx.toml",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:    "force",
+								Aliases: []string{"f"},
+								Usage:   "overwrite an existing tmx.toml",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return ConfigInitAction(ctx, cmd)
+						},
+					},
+					{
+						Name:  "check",
+						Usage: "validate all config files and report problems with their location (exits non-zero on errors)",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "strict",
+								Usage: "exit non-zero on warnings too",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return ConfigCheckAction(ctx, cmd)
+						},
+					},
+					{
+						Name:  "watch",
+						Usage: "reload the configuration whenever a config file changes and report problems",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return ConfigWatchAction(ctx, cmd)
+						},
+					},
+					{
+						Name:  "schema",
+						Usage: "print the JSON Schema of config files, for editor validation",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return ConfigSchemaAction(ctx, cmd)
+						},
+					},
+				},
+			},
+			{
+				Name:  "daemon",
+				Usage: "run the daemon that keeps the configuration, directory index and sessions in memory for faster invocations",
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return DaemonAction(ctx, cmd, sessionManager.Server())
+				},
+				Commands: []*cli.Command{
+					{
+						Name:  "status",
+						Usage: "report whether the daemon is running",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return DaemonStatusAction(ctx, cmd)
+						},
+					},
+					{
+						Name:  "stop",
+						Usage: "stop the running daemon",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return DaemonStopAction(ctx, cmd)
+						},
+					},
+				},
+			},
+			{
+				Name:    "workspace",
+				Aliases: []string{"ws"},
+				Usage:   "manage workspaces",
+				Commands: []*cli.Command{
+					{
+						Name:      "add",
+						Usage:     "add a workspace for a directory, inferring its windows from project markers",
+						ArgsUsage: "[dir]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "name",
+								Aliases: []string{"n"},
+								Usage:   "workspace name (default: the directory name)",
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "accept the inferred name and windows without asking",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return WorkspaceAddAction(ctx, cmd)
+						},
+					},
+				},
+			},
+			{
+				Name:  "index",
+				Usage: "manage the directory index cache",
+				Commands: []*cli.Command{
+					{
+						Name:      "rebuild",
+						Usage:     "rebuild the index for the configured search paths or a given directory",
+						ArgsUsage: "[path]",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:    "depth",
+								Aliases: []string{"d"},
+								Usage:   "search depth for nested directories (0 = use config)",
+							},
+						},
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return IndexRebuildAction(ctx, cmd, config)
+						},
+					},
+					{
+						Name:  "clear",
+						Usage: "remove all cached index entries",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							return IndexClearAction(ctx, cmd)
+						},
+					},
 				},
 			},
 		},
@@ -91,3 +299,35 @@ func Run() {
 		os.Exit(1)
 	}
 }
+
+// useDaemon reports whether the invocation may be served by a running daemon
+func useDaemon(cmd *cli.Command) bool {
+	return !cmd.Bool("no-daemon") && cmd.Args().First() != "daemon"
+}
+
+// tmuxServer returns the tmux server selected with --socket-path or --socket-name
+func tmuxServer(cmd *cli.Command) session.Server {
+	return session.Server{Name: cmd.String("socket-name"), Path: cmd.String("socket-path")}
+}
+
+// daemonProfile returns the profile chosen with --profile or $TMX_PROFILE, which a daemon
+// must have been started with to serve the invocation. It is empty when the profile
+// follows the hostname.
+func daemonProfile(cmd *cli.Command) string {
+	return cmp.Or(cmd.String("profile"), os.Getenv("TMX_PROFILE"))
+}
+
+// connectDaemon returns a client for the running daemon, or nil when there is none. A
+// daemon serves the profile and tmux server it was started with, so asking for others
+// with --profile, $TMX_PROFILE or the socket flags works without it.
+func connectDaemon(profile string, tmux session.Server) *daemon.Client {
+	client, err := daemon.Connect()
+	if err != nil {
+		return nil
+	}
+	status, err := client.Status()
+	if err != nil || status.Profile != profile || status.Server != tmux {
+		return nil
+	}
+	return client
+}
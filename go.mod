@@ -5,7 +5,9 @@ go 1.24.1
 require (
 	github.com/BurntSushi/toml v1.5.0
 	github.com/fatih/color v1.18.0
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/urfave/cli/v3 v3.1.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
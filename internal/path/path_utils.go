@@ -7,6 +7,8 @@ import (
 	"github.com/urfave/cli/v3"
 )
 
+// GetWorkingDirPath returns the directory passed as the first argument, or an empty
+// string when none is given so that the configured search paths are used instead
 func GetWorkingDirPath(cmd *cli.Command) (string, error) {
 	if cmd.Args().Present() && cmd.Args().First() != "" {
 		arg := cmd.Args().First()
@@ -18,11 +20,6 @@ func GetWorkingDirPath(cmd *cli.Command) (string, error) {
 		return arg, nil
 	}
 
-	// Home dir serves as a fallback if no argument is provided
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %v", err)
-	}
-
-	return homeDir, nil
+	// The selector falls back to the home directory if no search paths are configured
+	return "", nil
 }
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// CheckResult holds the problems found in the configuration directory by Check
+type CheckResult struct {
+	Dir      string
+	Files    []string
+	Errors   []ConfigError // Problems that make tmx ignore part of the config
+	Warnings []ConfigError // Likely mistakes that do not prevent the config from loading
+}
+
+// Check validates every configuration file with the given profile applied and reports
+// problems with their location
+func Check(profile string) (*CheckResult, error) {
+	path, err := getPath()
+	if err != nil {
+		return nil, err
+	}
+	return CheckDir(path, profile), nil
+}
+
+// CheckDir validates the configuration files in path, including the files they include.
+// On top of the errors reported by ParseConfig, it flags unknown keys, workspaces
+// shadowed by an earlier one matching the same directory name, and directories that do
+// not exist.
+func CheckDir(path, profile string) *CheckResult {
+	result := &CheckResult{Dir: path}
+
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		result.Errors = append(result.Errors, ConfigError{File: path, Error: fmt.Errorf("config directory not found")})
+		return result
+	}
+
+	l := loadConfig(path, profile)
+	result.Errors = append(result.Errors, l.errors...)
+
+	result.Files = l.files
+	for _, name := range l.files {
+		result.checkFile(path, name, l.fileProfiles[name])
+	}
+
+	result.checkWorkspaces(path, l.config.Workspace, l.workspaceOrigins)
+
+	// Report problems in the order files are merged, then top to bottom, with problems of
+	// the directory itself first. Files keep their names relative to the config directory,
+	// like the locations that messages refer to.
+	for _, findings := range [][]ConfigError{result.Errors, result.Warnings} {
+		slices.SortStableFunc(findings, func(a, b ConfigError) int {
+			return cmp.Or(cmp.Compare(slices.Index(l.files, a.File), slices.Index(l.files, b.File)),
+				cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+		})
+	}
+
+	return result
+}
+
+// checkFile reports unknown keys and missing search paths in a single file, including
+// the search paths of the profile applied to it, if any. Files that fail to decode are
+// already reported by parseConfigDir.
+func (r *CheckResult) checkFile(dir, name, profile string) {
+	content, err := os.ReadFile(configPath(dir, name))
+	if err != nil {
+		return
+	}
+
+	var config Config
+	unknown, err := decodeConfig(name, content, &config)
+	if err != nil {
+		return
+	}
+
+	for _, key := range unknown {
+		r.Errors = append(r.Errors, ConfigError{File: name, Line: key.line, Column: key.col, Error: fmt.Errorf("unknown key %q", key.key)})
+	}
+
+	r.checkSearchPaths(dir, name, "", config.SearchPaths)
+	if section, ok := config.Profile[profile]; ok && profile != "" {
+		r.checkSearchPaths(dir, name, profileTable(profile), section.SearchPaths)
+	}
+}
+
+// checkSearchPaths reports the search paths, defined in the given table of a file, that
+// do not exist
+func (r *CheckResult) checkSearchPaths(dir, name, table string, paths []SearchPathConfig) {
+	for i, sp := range paths {
+		if sp.Path == "" {
+			continue
+		}
+		if _, err := os.Stat(expandHome(sp.Path)); err != nil {
+			err := &tableError{table: table + "search_paths", index: i, err: fmt.Errorf("search path %s does not exist", sp.Path)}
+			r.Warnings = append(r.Warnings, newConfigError(dir, name, err))
+		}
+	}
+}
+
+// checkWorkspaces reports shadowed and missing workspaces across all files
+func (r *CheckResult) checkWorkspaces(dir string, workspaces []WorkspaceConfig, origins []origin) {
+	workspaceError := func(i int, err error) ConfigError {
+		return newConfigError(dir, origins[i].file, origins[i].wrap(err))
+	}
+	location := func(i int) string {
+		line, col := locateEntry(dir, origins[i])
+		return ConfigError{File: origins[i].file, Line: line, Column: col}.Location()
+	}
+
+	for i, ws := range workspaces {
+		// Conflicting names and directories are already rejected while merging
+		for j := range i {
+			other := workspaces[j]
+			if filepath.Base(other.Directory) == filepath.Base(ws.Directory) {
+				err := fmt.Errorf("workspace %q is shadowed by workspace %q at %s: both match directories named %q",
+					ws.Name, other.Name, location(j), filepath.Base(ws.Directory))
+				r.Warnings = append(r.Warnings, workspaceError(i, err))
+				break
+			}
+		}
+
+		if _, err := os.Stat(ws.Directory); err != nil {
+			err := fmt.Errorf("workspace %q: directory %s does not exist", ws.Name, ws.Directory)
+			r.Warnings = append(r.Warnings, workspaceError(i, err))
+		}
+	}
+}
+
+// locateUnknownKey returns the position of an unknown key, trying table headers for
+// the full key before assignments of its last part
+func locateUnknownKey(content string, key toml.Key) (line, col int) {
+	header := regexp.MustCompile(`^(\s*)\[\[?\s*` + regexp.QuoteMeta(key.String()) + `\s*\]\]?`)
+	for i, text := range strings.Split(content, "\n") {
+		if m := header.FindStringSubmatch(text); m != nil {
+			return i + 1, len(m[1]) + 1
+		}
+	}
+	return locateKey(content, key[len(key)-1])
+}
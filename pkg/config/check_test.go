@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFiles writes the given files into a new config directory
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// findingStrings formats findings relative to dir for comparison
+func findingStrings(dir string, findings []ConfigError) []string {
+	var lines []string
+	for _, f := range findings {
+		lines = append(lines, strings.TrimPrefix(f.String(), dir+string(filepath.Separator)))
+	}
+	return lines
+}
+
+func TestCheckDir(t *testing.T) {
+	existing := t.TempDir()
+	dir := writeConfigFiles(t, map[string]string{
+		"a.toml": `search_depth = 2
+serch_mode = "project"
+
+[[workspace]]
+directory = "` + existing + `"
+name = "main"
+windows = [{name = "a", comand = "ls"}]
+
+[[workspace]]
+directory = "/nonexistent/api"
+name = "api"
+`,
+		"b.toml": `[[workspace]]
+directory = "/elsewhere/` + filepath.Base(existing) + `"
+name = "shadowed"
+
+  [[workspace]]
+  directory = "/nonexistent/api2"
+  name = "api"
+`,
+		"c.toml": `max_recent = 5
+search_depth = "deep"
+`,
+		"d.toml": `search_mode = "tree"`,
+	})
+
+	result := CheckDir(dir, "")
+
+	expectedErrors := []string{
+		`a.toml:2:1: unknown key "serch_mode"`,
+		`a.toml:7:25: unknown key "workspace.windows.comand"`,
+		`b.toml:5:3: workspace "api" has the same name as workspace "api" at a.toml:9:1; set override = true to replace it`,
+		`c.toml:2:1: failed to decode TOML: toml: line 2 (last key "search_depth"): incompatible types: TOML value has type string; destination has type integer`,
+		`d.toml:1:1: invalid search mode "tree" (expected "directory" or "project")`,
+	}
+	if got := findingStrings(dir, result.Errors); strings.Join(got, "\n") != strings.Join(expectedErrors, "\n") {
+		t.Errorf("Errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expectedErrors, "\n"))
+	}
+
+	expectedWarnings := []string{
+		`a.toml:9:1: workspace "api": directory /nonexistent/api does not exist`,
+		`b.toml:1:1: workspace "shadowed" is shadowed by workspace "main" at a.toml:4:1: both match directories named "` + filepath.Base(existing) + `"`,
+		`b.toml:1:1: workspace "shadowed": directory /elsewhere/` + filepath.Base(existing) + ` does not exist`,
+	}
+	if got := findingStrings(dir, result.Warnings); strings.Join(got, "\n") != strings.Join(expectedWarnings, "\n") {
+		t.Errorf("Warnings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expectedWarnings, "\n"))
+	}
+
+	if len(result.Files) != 4 {
+		t.Errorf("Expected 4 checked files, got %v", result.Files)
+	}
+}
+
+func TestCheckDirMergeOrder(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": `serch_depth = 2
+
+[[workspace]]
+directory = "/src/api"
+name = "api"
+`,
+	})
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "conf.d", "10-work.toml"), []byte(`[[workspace]]
+directory = "/src/other"
+name = "api"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := CheckDir(dir, "")
+
+	// tmx.toml is merged before conf.d, and every location names files the same way
+	expected := []string{
+		`tmx.toml:1:1: unknown key "serch_depth"`,
+		`conf.d/10-work.toml:1:1: workspace "api" has the same name as workspace "api" at tmx.toml:3:1; set override = true to replace it`,
+	}
+	var got []string
+	for _, e := range result.Errors {
+		got = append(got, e.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestCheckDirClean(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": `[[workspace]]
+directory = "` + t.TempDir() + `"
+name = "clean"
+windows = [{name = "editor", command = "nvim", env = {A = "1"}}]
+`,
+	})
+
+	result := CheckDir(dir, "")
+	if len(result.Errors) > 0 || len(result.Warnings) > 0 {
+		t.Errorf("Expected no findings, got errors %v and warnings %v", result.Errors, result.Warnings)
+	}
+}
+
+func TestCheckDirMissing(t *testing.T) {
+	result := CheckDir(filepath.Join(t.TempDir(), "missing"), "")
+	if len(result.Errors) != 1 {
+		t.Errorf("Expected a single error for a missing directory, got %v", result.Errors)
+	}
+}
+
+func TestConfigErrorLocation(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": `
+[[workspace]]
+directory = "/a"
+name = "a"
+
+[[workspace]]
+directory = "/b"
+name = "a"
+`,
+	})
+
+	_, errors := parseConfigFile(dir)
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got: %v", errors)
+	}
+	if got := errors[0].String(); got != "tmx.toml:6:1: duplicate workspace name: a" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -6,34 +6,88 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/BurntSushi/toml"
+	"github.com/vbrdnk/tmx/pkg/frecency"
+)
+
+// Search modes supported by the search_mode option
+const (
+	SearchModeDirectory = "directory" // List every directory up to the search depth
+	SearchModeProject   = "project"   // List only project roots, identified by marker files
 )
 
 // ConfigError represents an error that occurred while processing a specific config file
 type ConfigError struct {
-	File  string
-	Error error
+	File   string
+	Line   int // 0 when unknown
+	Column int // 0 when unknown
+	Error  error
 }
 
 // Config represents the application configuration
 type Config struct {
 	Workspace   []WorkspaceConfig `toml:"workspace"`
+	Template    []TemplateConfig  `toml:"template"`
 	SearchDepth int               `toml:"search_depth"` // Default: 1, 0 = unlimited
 	UseZoxide   *bool             `toml:"use_zoxide"`   // Default: true, pointer to distinguish unset from false
 	MaxRecent   *int              `toml:"max_recent"`   // Default: 10, pointer to distinguish unset from explicit 0
+
+	SearchExclude []string `toml:"search_exclude"` // Extra gitignore-style patterns to skip during search
+	SearchInclude []string `toml:"search_include"` // Patterns reported even when hidden, excluded or ignored
+	SearchHidden  *bool    `toml:"search_hidden"`  // Default: true, include hidden directories
+	UseGitignore  *bool    `toml:"use_gitignore"`  // Default: false, honour .gitignore and .ignore files
+
+	SearchMode     string   `toml:"search_mode"`     // Default: "directory"
+	ProjectMarkers []string `toml:"project_markers"` // Files or directories marking a project root in project mode
+
+	SearchPaths []SearchPathConfig `toml:"search_paths"` // Directories searched when no path argument is given
+	IndexCache  *bool              `toml:"index_cache"`  // Default: false, serve search results from an on-disk index
+
+	FrecencySource string `toml:"frecency_source"` // Default: "zoxide"
+	FrecencyRecord *bool  `toml:"frecency_record"` // Default: true, record directories opened through tmx
+
+	FrecencyLimit      *int     `toml:"frecency_limit"`       // Default: 30 per search path, 0 = unlimited
+	FrecencyMinScore   *float64 `toml:"frecency_min_score"`   // Default: 0, suggestions scoring lower are hidden
+	FrecencyShowScores *bool    `toml:"frecency_show_scores"` // Default: true, show scores in the picker
+
+	WorktreeMode *bool `toml:"worktree_mode"` // Default: false, name sessions after the git repository and branch
+
+	Include []string          `toml:"include"` // Further files to merge, relative to this file; ~ and globs are expanded
+	Profile map[string]Config `toml:"profile"` // Sections merged only when their profile is active
+}
+
+// SearchPathConfig represents a single directory searched by default
+type SearchPathConfig struct {
+	Path    string   `toml:"path"`
+	Depth   int      `toml:"depth"`   // Default: global search depth
+	Exclude []string `toml:"exclude"` // Added to the global search_exclude patterns
+	Label   string   `toml:"label"`   // Optional marker shown next to results in the picker
 }
 
 // WindowConfig represents a single window configuration
 type WindowConfig struct {
-	Name    string `toml:"name"`
-	Command string `toml:"command"`
+	Name    string            `toml:"name"`
+	Command string            `toml:"command"`
+	Env     map[string]string `toml:"env"`   // Environment variables set for this window only
+	Dir     string            `toml:"dir"`   // Working directory, absolute or relative to the session directory
+	Focus   bool              `toml:"focus"` // Select this window once the session is created
+}
+
+// HooksConfig holds commands run at points of a session's lifecycle
+type HooksConfig struct {
+	OnCreate []string `toml:"on_create"` // Shell commands run in the workspace directory after the session is created
 }
 
 // WorkspaceConfig represents a single workspace configuration
 type WorkspaceConfig struct {
-	Directory string         `toml:"directory"`
-	Name      string         `toml:"name"`
-	Windows   []WindowConfig `toml:"windows"`
+	Directory string            `toml:"directory"`
+	Name      string            `toml:"name"`
+	Extends   string            `toml:"extends"` // Name of the template providing default windows, hooks and env
+	Windows   []WindowConfig    `toml:"windows"`
+	Hooks     HooksConfig       `toml:"hooks"`
+	Env       map[string]string `toml:"env"`      // Environment variables set for the session
+	EnvFile   string            `toml:"env_file"` // Dotenv file loaded into the session env, relative to the session directory
+	Override  bool              `toml:"override"` // Replace a workspace with the same name or directory from an earlier file
+	Socket    string            `toml:"socket"`   // tmux server of the session: a socket name (tmux -L) or, with a slash, a socket path (tmux -S)
 }
 
 // GetUseZoxide safely returns the UseZoxide value, defaulting to true if nil
@@ -52,6 +106,86 @@ func (c *Config) GetMaxRecent() int {
 	return *c.MaxRecent
 }
 
+// GetSearchHidden safely returns the SearchHidden value, defaulting to true if nil
+func (c *Config) GetSearchHidden() bool {
+	if c.SearchHidden == nil {
+		return true
+	}
+	return *c.SearchHidden
+}
+
+// GetUseGitignore safely returns the UseGitignore value, defaulting to false if nil
+func (c *Config) GetUseGitignore() bool {
+	if c.UseGitignore == nil {
+		return false
+	}
+	return *c.UseGitignore
+}
+
+// GetIndexCache safely returns the IndexCache value, defaulting to false if nil
+func (c *Config) GetIndexCache() bool {
+	if c.IndexCache == nil {
+		return false
+	}
+	return *c.IndexCache
+}
+
+// GetFrecencySource returns the frecency source name, defaulting to zoxide if unset
+func (c *Config) GetFrecencySource() string {
+	if c.FrecencySource != "" {
+		return c.FrecencySource
+	}
+	return frecency.SourceZoxide
+}
+
+// GetFrecencyRecord safely returns the FrecencyRecord value, defaulting to true if nil
+func (c *Config) GetFrecencyRecord() bool {
+	if c.FrecencyRecord == nil {
+		return true
+	}
+	return *c.FrecencyRecord
+}
+
+// GetFrecencyLimit safely returns the FrecencyLimit value, defaulting to 30 if nil
+func (c *Config) GetFrecencyLimit() int {
+	if c.FrecencyLimit == nil {
+		return frecency.DefaultLimit
+	}
+	return *c.FrecencyLimit
+}
+
+// GetFrecencyMinScore safely returns the FrecencyMinScore value, defaulting to 0 if nil
+func (c *Config) GetFrecencyMinScore() float64 {
+	if c.FrecencyMinScore == nil {
+		return 0
+	}
+	return *c.FrecencyMinScore
+}
+
+// GetFrecencyShowScores safely returns the FrecencyShowScores value, defaulting to true if nil
+func (c *Config) GetFrecencyShowScores() bool {
+	if c.FrecencyShowScores == nil {
+		return true
+	}
+	return *c.FrecencyShowScores
+}
+
+// GetWorktreeMode safely returns the WorktreeMode value, defaulting to false if nil
+func (c *Config) GetWorktreeMode() bool {
+	if c.WorktreeMode == nil {
+		return false
+	}
+	return *c.WorktreeMode
+}
+
+// GetSearchMode returns the search mode, defaulting to directory mode if unset
+func (c *Config) GetSearchMode() string {
+	if c.SearchMode != "" {
+		return c.SearchMode
+	}
+	return SearchModeDirectory
+}
+
 // GetSearchDepth returns the search depth, with a minimum of 1
 func (c *Config) GetSearchDepth(cliDepth int) int {
 	// CLI flag takes precedence
@@ -68,16 +202,23 @@ func (c *Config) GetSearchDepth(cliDepth int) int {
 	return 1
 }
 
-// ParseConfig reads and parses all configuration files
+// ParseConfig reads and parses all configuration files, applying the profile selected
+// by the TMX_PROFILE environment variable or the hostname
 func ParseConfig() (*Config, []ConfigError) {
+	return ParseConfigProfile("")
+}
+
+// ParseConfigProfile reads and parses all configuration files, applying the given
+// profile. An empty profile falls back to TMX_PROFILE, then to the hostname.
+func ParseConfigProfile(profile string) (*Config, []ConfigError) {
 	path, err := getPath()
 	if err != nil {
 		return nil, []ConfigError{{File: "path", Error: err}}
 	}
 
-	config, errors := parseConfigFile(path)
-	applyDefaults(config)
-	return config, errors
+	l := loadConfig(path, profile)
+	applyDefaults(l.config)
+	return l.config, l.errors
 }
 
 // applyDefaults sets default values for unset configuration options
@@ -93,64 +234,156 @@ func applyDefaults(config *Config) {
 		defaultMaxRecent := 10
 		config.MaxRecent = &defaultMaxRecent
 	}
+	if config.SearchHidden == nil {
+		defaultSearchHidden := true
+		config.SearchHidden = &defaultSearchHidden
+	}
+	if config.UseGitignore == nil {
+		defaultUseGitignore := false
+		config.UseGitignore = &defaultUseGitignore
+	}
+	if config.IndexCache == nil {
+		defaultIndexCache := false
+		config.IndexCache = &defaultIndexCache
+	}
+	if config.FrecencyRecord == nil {
+		defaultFrecencyRecord := true
+		config.FrecencyRecord = &defaultFrecencyRecord
+	}
+	if config.FrecencyLimit == nil {
+		defaultFrecencyLimit := frecency.DefaultLimit
+		config.FrecencyLimit = &defaultFrecencyLimit
+	}
+	if config.FrecencyShowScores == nil {
+		defaultFrecencyShowScores := true
+		config.FrecencyShowScores = &defaultFrecencyShowScores
+	}
+	if config.WorktreeMode == nil {
+		defaultWorktreeMode := false
+		config.WorktreeMode = &defaultWorktreeMode
+	}
 }
 
 // parseConfigFile reads and parses all TOML files in the given directory
 func parseConfigFile(path string) (*Config, []ConfigError) {
-	config := &Config{
-		Workspace: []WorkspaceConfig{},
-	}
+	l := parseConfigDir(path, nil)
+	return l.config, l.errors
+}
+
+// origin records where a workspace or template was defined: the file, the array of
+// tables it belongs to (e.g. "workspace" or "profile.laptop.workspace") and its index
+// among the entries of that array
+type origin struct {
+	file  string
+	table string
+	index int
+}
 
-	var errors []ConfigError
+// wrap attaches the location of o to err
+func (o origin) wrap(err error) error {
+	return &tableError{table: o.table, index: o.index, err: err}
+}
 
-	// Ensure the config directory exists
-	if err := ensureConfigDir(path); err != nil {
-		return config, []ConfigError{{File: path, Error: err}}
+// mergeSettings merges the global settings of a single file or profile into config.
+// Settings that take a single value are overridden, lists are combined.
+func mergeSettings(config, tempConfig *Config) error {
+	if tempConfig.FrecencySource != "" {
+		config.FrecencySource = tempConfig.FrecencySource
+	}
+	if tempConfig.SearchMode != "" {
+		config.SearchMode = tempConfig.SearchMode
 	}
 
-	files, err := os.ReadDir(path)
-	if err != nil {
-		return config, []ConfigError{{File: path, Error: err}}
+	// Merge global config options (last file wins for non-array fields)
+	if tempConfig.SearchDepth > 0 {
+		config.SearchDepth = tempConfig.SearchDepth
+	}
+	if tempConfig.UseZoxide != nil {
+		config.UseZoxide = tempConfig.UseZoxide
+	}
+	if tempConfig.MaxRecent != nil {
+		config.MaxRecent = tempConfig.MaxRecent
+	}
+	if tempConfig.SearchHidden != nil {
+		config.SearchHidden = tempConfig.SearchHidden
+	}
+	if tempConfig.UseGitignore != nil {
+		config.UseGitignore = tempConfig.UseGitignore
+	}
+	if tempConfig.IndexCache != nil {
+		config.IndexCache = tempConfig.IndexCache
+	}
+	if tempConfig.FrecencyRecord != nil {
+		config.FrecencyRecord = tempConfig.FrecencyRecord
+	}
+	if tempConfig.FrecencyMinScore != nil {
+		config.FrecencyMinScore = tempConfig.FrecencyMinScore
+	}
+	if tempConfig.FrecencyShowScores != nil {
+		config.FrecencyShowScores = tempConfig.FrecencyShowScores
+	}
+	if tempConfig.WorktreeMode != nil {
+		config.WorktreeMode = tempConfig.WorktreeMode
 	}
 
-	for _, file := range files {
-		// Skip non-TOML files and hidden files
-		if !strings.HasSuffix(file.Name(), ".toml") || strings.HasPrefix(file.Name(), ".") {
-			continue
-		}
+	// Search patterns accumulate across files
+	config.SearchExclude = append(config.SearchExclude, tempConfig.SearchExclude...)
+	config.SearchInclude = append(config.SearchInclude, tempConfig.SearchInclude...)
+	config.ProjectMarkers = append(config.ProjectMarkers, tempConfig.ProjectMarkers...)
 
-		filePath := filepath.Join(path, file.Name())
-		tempConfig, err := parseSingleConfigFile(filePath)
-		if err != nil {
-			errors = append(errors, ConfigError{File: file.Name(), Error: err})
-			continue
-		}
+	// Append search paths, expanding a leading ~ to the home directory
+	for _, sp := range tempConfig.SearchPaths {
+		sp.Path = expandHome(sp.Path)
+		config.SearchPaths = append(config.SearchPaths, sp)
+	}
 
-		// Validate the workspace configurations
-		if err := validateWorkspaceConfigs(tempConfig.Workspace); err != nil {
-			errors = append(errors, ConfigError{File: file.Name(), Error: err})
-			continue
+	if tempConfig.FrecencyLimit != nil {
+		if *tempConfig.FrecencyLimit < 0 {
+			return &keyError{key: "frecency_limit", err: fmt.Errorf("frecency_limit cannot be negative")}
 		}
+		config.FrecencyLimit = tempConfig.FrecencyLimit
+	}
 
-		// Merge global config options (last file wins for non-array fields)
-		if tempConfig.SearchDepth > 0 {
-			config.SearchDepth = tempConfig.SearchDepth
-		}
-		if tempConfig.UseZoxide != nil {
-			config.UseZoxide = tempConfig.UseZoxide
-		}
-		if tempConfig.MaxRecent != nil {
-			config.MaxRecent = tempConfig.MaxRecent
-		}
+	return nil
+}
+
+// validateConfigFile validates a single, unmerged config file
+func validateConfigFile(config *Config) error {
+	// Validate the workspace configurations
+	if err := validateWorkspaceConfigs(config.Workspace); err != nil {
+		return err
+	}
+
+	// Validate the templates
+	if err := validateTemplateConfigs(config.Template); err != nil {
+		return err
+	}
+
+	// Expand ~ and environment variables and check {{.Var}} placeholders
+	if err := interpolateConfig(config); err != nil {
+		return err
+	}
+
+	// Validate the search paths
+	if err := validateSearchPaths(config.SearchPaths); err != nil {
+		return err
+	}
+
+	if config.FrecencySource != "" && !frecency.IsKnown(config.FrecencySource) {
+		err := fmt.Errorf("unknown frecency source %q (expected one of %s)", config.FrecencySource, strings.Join(frecency.Names(), ", "))
+		return &keyError{key: "frecency_source", err: err}
+	}
 
-		// Append workspace configurations
-		config.Workspace = append(config.Workspace, tempConfig.Workspace...)
+	if config.SearchMode != "" {
+		if err := ValidateSearchMode(config.SearchMode); err != nil {
+			return &keyError{key: "search_mode", err: err}
+		}
 	}
 
-	return config, errors
+	return nil
 }
 
-// parseSingleConfigFile reads and parses a single TOML configuration file
+// parseSingleConfigFile reads and parses a single TOML, YAML or JSON configuration file
 func parseSingleConfigFile(path string) (*Config, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -158,24 +391,63 @@ func parseSingleConfigFile(path string) (*Config, error) {
 	}
 
 	config := &Config{}
-	if _, err := toml.Decode(string(content), config); err != nil {
-		return nil, fmt.Errorf("failed to decode TOML: %w", err)
+	if _, err := decodeConfig(path, content, config); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
+// WithSearchMode returns a copy of c using the given search mode, or c itself when mode
+// is empty
+func (c *Config) WithSearchMode(mode string) (*Config, error) {
+	if mode == "" {
+		return c, nil
+	}
+	if err := ValidateSearchMode(mode); err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, nil
+	}
+	override := *c
+	override.SearchMode = mode
+	return &override, nil
+}
+
+// ValidateSearchMode checks that mode is one of the supported search modes
+func ValidateSearchMode(mode string) error {
+	switch mode {
+	case SearchModeDirectory, SearchModeProject:
+		return nil
+	}
+	return fmt.Errorf("invalid search mode %q (expected %q or %q)", mode, SearchModeDirectory, SearchModeProject)
+}
+
+// validateSearchPaths validates a list of search path configurations
+func validateSearchPaths(paths []SearchPathConfig) error {
+	for i, sp := range paths {
+		if sp.Path == "" {
+			return &tableError{table: "search_paths", index: i, err: fmt.Errorf("search path at index %d has an empty path", i)}
+		}
+		if sp.Depth < 0 {
+			return &tableError{table: "search_paths", index: i, err: fmt.Errorf("search path %q has a negative depth", sp.Path)}
+		}
+	}
+	return nil
+}
+
 // validateWorkspaceConfigs validates a list of workspace configurations
 func validateWorkspaceConfigs(workspaces []WorkspaceConfig) error {
 	seenNames := make(map[string]bool)
 
-	for _, ws := range workspaces {
+	for i, ws := range workspaces {
 		if err := validateWorkspaceConfig(ws); err != nil {
-			return err
+			return &tableError{table: "workspace", index: i, err: err}
 		}
 
 		if seenNames[ws.Name] {
-			return fmt.Errorf("duplicate workspace name: %s", ws.Name)
+			return &tableError{table: "workspace", index: i, err: fmt.Errorf("duplicate workspace name: %s", ws.Name)}
 		}
 		seenNames[ws.Name] = true
 	}
@@ -199,6 +471,21 @@ func validateWorkspaceConfig(ws WorkspaceConfig) error {
 		}
 	}
 
+	return validateFocus(ws.Windows, "workspace", ws.Name)
+}
+
+// validateFocus checks that at most one of windows is focused
+func validateFocus(windows []WindowConfig, kind, name string) error {
+	focused := ""
+	for _, w := range windows {
+		if !w.Focus {
+			continue
+		}
+		if focused != "" {
+			return fmt.Errorf("windows %q and %q in %s %q both set focus", focused, w.Name, kind, name)
+		}
+		focused = w.Name
+	}
 	return nil
 }
 
@@ -220,6 +507,18 @@ func ensureConfigDir(path string) error {
 	return nil
 }
 
+// expandHome replaces a leading ~ in path with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, path[1:])
+}
+
 // getPath returns the path to the configuration directory
 func getPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
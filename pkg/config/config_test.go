@@ -247,3 +247,212 @@ windows = [{name = "a"}, {name = "b"}]
 		t.Errorf("expected UseZoxide false from TOML, got true")
 	}
 }
+
+func TestParseConfigSearchFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"a.toml": `search_exclude = ["target", "vendor"]
+search_hidden = false
+`,
+		"b.toml": `search_exclude = [".venv"]
+search_include = [".config"]
+use_gitignore = true
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, errors := parseConfigFile(tmpDir)
+	if len(errors) > 0 {
+		t.Fatalf("expected no errors, got: %v", errors)
+	}
+
+	// Patterns accumulate across files in file name order
+	expectedExclude := []string{"target", "vendor", ".venv"}
+	if len(cfg.SearchExclude) != len(expectedExclude) {
+		t.Fatalf("expected SearchExclude %v, got %v", expectedExclude, cfg.SearchExclude)
+	}
+	for i, p := range expectedExclude {
+		if cfg.SearchExclude[i] != p {
+			t.Errorf("expected SearchExclude[%d] %q, got %q", i, p, cfg.SearchExclude[i])
+		}
+	}
+	if len(cfg.SearchInclude) != 1 || cfg.SearchInclude[0] != ".config" {
+		t.Errorf("expected SearchInclude [.config], got %v", cfg.SearchInclude)
+	}
+	if cfg.GetSearchHidden() {
+		t.Error("expected SearchHidden false from TOML")
+	}
+	if !cfg.GetUseGitignore() {
+		t.Error("expected UseGitignore true from TOML")
+	}
+}
+
+func TestSearchFilterDefaults(t *testing.T) {
+	cfg := &Config{}
+	if !cfg.GetSearchHidden() {
+		t.Error("expected SearchHidden to default to true")
+	}
+	if cfg.GetUseGitignore() {
+		t.Error("expected UseGitignore to default to false")
+	}
+
+	applyDefaults(cfg)
+	if cfg.SearchHidden == nil || cfg.UseGitignore == nil {
+		t.Error("expected applyDefaults to set SearchHidden and UseGitignore")
+	}
+}
+
+func TestParseConfigSearchMode(t *testing.T) {
+	t.Run("ProjectMode", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tomlData := `search_mode = "project"
+project_markers = ["go.mod", "flake.nix"]
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tomlData), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, errors := parseConfigFile(tmpDir)
+		if len(errors) > 0 {
+			t.Fatalf("expected no errors, got: %v", errors)
+		}
+		if cfg.GetSearchMode() != SearchModeProject {
+			t.Errorf("expected search mode %q, got %q", SearchModeProject, cfg.GetSearchMode())
+		}
+		if len(cfg.ProjectMarkers) != 2 || cfg.ProjectMarkers[1] != "flake.nix" {
+			t.Errorf("unexpected project markers: %v", cfg.ProjectMarkers)
+		}
+	})
+
+	t.Run("InvalidMode", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(`search_mode = "repos"`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, errors := parseConfigFile(tmpDir)
+		if len(errors) != 1 {
+			t.Fatalf("expected 1 error, got: %v", errors)
+		}
+		if cfg.GetSearchMode() != SearchModeDirectory {
+			t.Errorf("expected default search mode, got %q", cfg.GetSearchMode())
+		}
+	})
+}
+
+func TestParseConfigSearchPaths(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tomlData := `
+[[search_paths]]
+path = "~/Git"
+depth = 2
+label = "git"
+
+[[search_paths]]
+path = "/srv/work"
+exclude = ["vendor"]
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tomlData), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, errors := parseConfigFile(tmpDir)
+		if len(errors) > 0 {
+			t.Fatalf("expected no errors, got: %v", errors)
+		}
+		if len(cfg.SearchPaths) != 2 {
+			t.Fatalf("expected 2 search paths, got %d", len(cfg.SearchPaths))
+		}
+		first := cfg.SearchPaths[0]
+		if first.Path != filepath.Join(home, "Git") || first.Depth != 2 || first.Label != "git" {
+			t.Errorf("unexpected search path[0]: %+v", first)
+		}
+		second := cfg.SearchPaths[1]
+		if second.Path != "/srv/work" || len(second.Exclude) != 1 || second.Exclude[0] != "vendor" {
+			t.Errorf("unexpected search path[1]: %+v", second)
+		}
+	})
+
+	t.Run("EmptyPath", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tomlData := `
+[[search_paths]]
+label = "broken"
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tomlData), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, errors := parseConfigFile(tmpDir)
+		if len(errors) != 1 {
+			t.Fatalf("expected 1 error, got: %v", errors)
+		}
+		if len(cfg.SearchPaths) != 0 {
+			t.Errorf("expected invalid search paths to be skipped, got %v", cfg.SearchPaths)
+		}
+	})
+}
+
+func TestParseConfigFrecency(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg := &Config{}
+		if cfg.GetFrecencySource() != "zoxide" {
+			t.Errorf("expected default frecency source zoxide, got %q", cfg.GetFrecencySource())
+		}
+		if !cfg.GetFrecencyRecord() {
+			t.Error("expected FrecencyRecord to default to true")
+		}
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tomlData := `frecency_source = "autojump"
+frecency_record = false
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tomlData), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, errors := parseConfigFile(tmpDir)
+		if len(errors) > 0 {
+			t.Fatalf("expected no errors, got: %v", errors)
+		}
+		if cfg.GetFrecencySource() != "autojump" || cfg.GetFrecencyRecord() {
+			t.Errorf("unexpected frecency settings: %q, %v", cfg.GetFrecencySource(), cfg.GetFrecencyRecord())
+		}
+	})
+
+	t.Run("UnknownSource", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(`frecency_source = "jump"`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, errors := parseConfigFile(tmpDir)
+		if len(errors) != 1 {
+			t.Fatalf("expected 1 error, got: %v", errors)
+		}
+	})
+}
+
+func TestParseConfigWorktreeMode(t *testing.T) {
+	if (&Config{}).GetWorktreeMode() {
+		t.Error("expected WorktreeMode to default to false")
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte("worktree_mode = true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, errors := parseConfigFile(tmpDir)
+	if len(errors) > 0 {
+		t.Fatalf("expected no errors, got: %v", errors)
+	}
+	if !cfg.GetWorktreeMode() {
+		t.Error("expected WorktreeMode true from TOML")
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadEnvFile reads the variables defined in a dotenv file: one NAME=value per line,
+// optionally prefixed with "export", with "#" comments and blank lines ignored.
+// Values may be wrapped in single or double quotes; double-quoted values support
+// \n, \t, \" and \\ escapes. Values are taken literally otherwise.
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, lineNo)
+		}
+
+		env[name] = parseEnvValue(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// parseEnvValue unquotes a dotenv value and strips a trailing comment from unquoted ones
+func parseEnvValue(value string) string {
+	if len(value) >= 2 {
+		switch quote := value[0]; {
+		case quote == '\'' && strings.HasSuffix(value, "'"):
+			return value[1 : len(value)-1]
+		case quote == '"' && strings.HasSuffix(value, `"`):
+			return strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := `# Local settings
+AWS_PROFILE=staging
+export REGION = eu-west-1
+
+SINGLE='literal $HOME # not a comment'
+DOUBLE="line one\nline \"two\""
+EMPTY=
+TRAILING=value # comment
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := ReadEnvFile(path)
+	if err != nil {
+		t.Fatalf("ReadEnvFile() error = %v", err)
+	}
+
+	expected := map[string]string{
+		"AWS_PROFILE": "staging",
+		"REGION":      "eu-west-1",
+		"SINGLE":      "literal $HOME # not a comment",
+		"DOUBLE":      "line one\nline \"two\"",
+		"EMPTY":       "",
+		"TRAILING":    "value",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("ReadEnvFile() = %v, want %v", env, expected)
+	}
+
+	t.Run("InvalidLine", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("VALID=1\nnot a variable\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadEnvFile(path); err == nil || err.Error() != path+":2: expected NAME=value" {
+			t.Errorf("ReadEnvFile() error = %v, want the invalid line reported", err)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		if _, err := ReadEnvFile(filepath.Join(t.TempDir(), ".env")); err == nil {
+			t.Error("Expected an error for a missing file")
+		}
+	})
+}
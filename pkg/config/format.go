@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file formats, chosen by file extension
+const (
+	formatTOML = "TOML"
+	formatYAML = "YAML"
+	formatJSON = "JSON"
+)
+
+// formatExtensions maps the extensions of config files to their format
+var formatExtensions = map[string]string{
+	".toml": formatTOML,
+	".yaml": formatYAML,
+	".yml":  formatYAML,
+	".json": formatJSON,
+}
+
+// schemaKey may appear at the top of YAML and JSON files to point editors at the
+// JSON Schema; it is not a setting
+const schemaKey = "$schema"
+
+// fileFormat returns the format of the config file called name, or "" when its
+// extension is not a config file extension
+func fileFormat(name string) string {
+	return formatExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// unknownKey is a key of a config file that does not match any setting
+type unknownKey struct {
+	key       string
+	line, col int
+}
+
+// positionError is an error at a known position of a config file
+type positionError struct {
+	line, col int
+	err       error
+}
+
+func (e *positionError) Error() string { return e.err.Error() }
+func (e *positionError) Unwrap() error { return e.err }
+
+// decodeConfig decodes content, read from the file called name, into config according
+// to the file extension, and returns the keys that do not match any setting. YAML and
+// JSON files use the same keys as TOML files.
+func decodeConfig(name string, content []byte, config *Config) ([]unknownKey, error) {
+	var unknown []unknownKey
+	var err error
+	switch format := fileFormat(name); format {
+	case formatTOML:
+		unknown, err = decodeTOML(content, config)
+	case formatYAML:
+		unknown, err = decodeYAML(content, config)
+	case formatJSON:
+		unknown, err = decodeJSON(content, config)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", filepath.Ext(name))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", fileFormat(name), err)
+	}
+	return unknown, nil
+}
+
+// decodeTOML decodes a TOML document into config
+func decodeTOML(content []byte, config *Config) ([]unknownKey, error) {
+	md, err := toml.Decode(string(content), config)
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []unknownKey
+	undecoded := md.Undecoded()
+	for _, key := range undecoded {
+		// Only report the outermost unknown key, not every key nested below it
+		if slices.ContainsFunc(undecoded, func(other toml.Key) bool {
+			return len(other) < len(key) && slices.Equal(other, key[:len(other)])
+		}) {
+			continue
+		}
+
+		line, col := locateUnknownKey(string(content), key)
+		unknown = append(unknown, unknownKey{key: key.String(), line: line, col: col})
+	}
+	return unknown, nil
+}
+
+// yamlErrorPattern matches the position given in YAML syntax errors
+var yamlErrorPattern = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// decodeYAML decodes a YAML document into config
+func decodeYAML(content []byte, config *Config) ([]unknownKey, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		if m := yamlErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return nil, &positionError{line: line, err: err}
+		}
+		return nil, err
+	}
+
+	// An empty document sets nothing
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	d := &nodeDecoder{}
+	if err := d.decode(doc.Content[0], reflect.ValueOf(config).Elem(), nil); err != nil {
+		return nil, err
+	}
+	return d.unknown, nil
+}
+
+// decodeJSON decodes a JSON document into config. JSON is checked with the standard
+// library first, so that anything YAML would accept beyond JSON is rejected, and then
+// decoded as YAML, of which it is a subset.
+func decodeJSON(content []byte, config *Config) ([]unknownKey, error) {
+	var value any
+	if err := json.Unmarshal(content, &value); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := offsetPosition(content, syntaxErr.Offset)
+			return nil, &positionError{line: line, col: col, err: err}
+		}
+		return nil, err
+	}
+	return decodeYAML(content, config)
+}
+
+// offsetPosition converts a byte offset in content to a line and column
+func offsetPosition(content []byte, offset int64) (line, col int) {
+	offset = min(max(offset, 1), int64(len(content)))
+	before := content[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - (bytes.LastIndexByte(before, '\n') + 1)
+	return line, col
+}
+
+// nodeDecoder decodes YAML nodes into config structs, matching mapping keys against
+// their toml tags and collecting the keys that match no field
+type nodeDecoder struct {
+	unknown []unknownKey
+}
+
+// decode decodes node into v, where path is the key path of node used in messages
+func (d *nodeDecoder) decode(node *yaml.Node, v reflect.Value, path []string) error {
+	if node.Kind == yaml.AliasNode {
+		return d.decode(node.Alias, v, path)
+	}
+
+	// An explicit null leaves the setting unset
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := d.decode(node, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return d.typeError(node, path, "a mapping")
+		}
+		fields := tomlFields(v.Type())
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			key := keyNode.Value
+			index, ok := fields[key]
+			if !ok {
+				if len(path) == 0 && key == schemaKey {
+					continue
+				}
+				d.unknown = append(d.unknown, unknownKey{key: strings.Join(append(slices.Clone(path), key), "."), line: keyNode.Line, col: keyNode.Column})
+				continue
+			}
+			if err := d.decode(valueNode, v.Field(index), append(slices.Clone(path), key)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return d.typeError(node, path, "a mapping")
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decode(node.Content[i+1], elem, append(slices.Clone(path), key)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key), elem)
+		}
+		v.Set(m)
+		return nil
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return d.typeError(node, path, "a list")
+		}
+		s := reflect.MakeSlice(v.Type(), len(node.Content), len(node.Content))
+		for i, item := range node.Content {
+			if err := d.decode(item, s.Index(i), append(slices.Clone(path), strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+
+	// Scalars are decoded by the YAML library, which knows its own tags
+	if node.Kind != yaml.ScalarNode || node.Decode(v.Addr().Interface()) != nil {
+		return d.typeError(node, path, scalarKinds[v.Kind()])
+	}
+	return nil
+}
+
+// scalarKinds names the kinds of scalar settings in type errors
+var scalarKinds = map[reflect.Kind]string{
+	reflect.String:  "a string",
+	reflect.Int:     "an integer",
+	reflect.Float64: "a number",
+	reflect.Bool:    "a boolean",
+}
+
+// typeError reports that the value of node, at path, is not of the expected kind
+func (d *nodeDecoder) typeError(node *yaml.Node, path []string, expected string) error {
+	found := "a mapping"
+	switch {
+	case node.Kind == yaml.SequenceNode:
+		found = "a list"
+	case node.Kind == yaml.ScalarNode:
+		found = fmt.Sprintf("%q", node.Value)
+	}
+	return &positionError{
+		line: node.Line,
+		col:  node.Column,
+		err:  fmt.Errorf("%s: expected %s, found %s", strings.Join(path, "."), expected, found),
+	}
+}
+
+// tomlFields maps the toml keys of the fields of the struct type t to their index
+func tomlFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// locateNode returns the position of the value at path in a YAML or JSON document, where
+// path elements are mapping keys or sequence indexes. Mapping values are located at their
+// key. It returns zeros when the path cannot be found.
+func locateNode(content []byte, path []string) (line, col int) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return 0, 0
+	}
+
+	node := doc.Content[0]
+	line, col = node.Line, node.Column
+	for _, elem := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			// Keys and values alternate in the content of a mapping
+			i := -1
+			for k := 0; k+1 < len(node.Content); k += 2 {
+				if node.Content[k].Value == elem {
+					i = k
+					break
+				}
+			}
+			if i < 0 {
+				return 0, 0
+			}
+			line, col = node.Content[i].Line, node.Content[i].Column
+			node = node.Content[i+1]
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(elem)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				return 0, 0
+			}
+			node = node.Content[index]
+			line, col = node.Line, node.Column
+		default:
+			return 0, 0
+		}
+	}
+	return line, col
+}
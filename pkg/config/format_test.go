@@ -0,0 +1,177 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigFormats(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "search_depth = 1\n",
+		"a.yaml": `# yaml-language-server: $schema=tmx.schema.json
+search_depth: 2
+use_zoxide: false
+search_exclude: [target]
+search_paths:
+  - path: /src
+    label: src
+workspace:
+  - directory: /src/api
+    name: api
+    windows:
+      - name: editor
+        command: nvim
+        focus: true
+      - name: server
+        env:
+          PORT: "8080"
+profile:
+  laptop:
+    max_recent: 3
+`,
+		"b.json": `{
+  "$schema": "tmx.schema.json",
+  "frecency_min_score": 1.5,
+  "search_exclude": ["vendor"],
+  "workspace": [{"directory": "/src/web", "name": "web", "hooks": {"on_create": ["npm install"]}}]
+}`,
+	})
+
+	l := parseConfigDir(dir, []string{"laptop"})
+	if len(l.errors) > 0 {
+		t.Fatalf("expected no errors, got: %v", l.errors)
+	}
+
+	cfg := l.config
+	if cfg.SearchDepth != 2 || cfg.GetUseZoxide() || cfg.GetMaxRecent() != 3 || cfg.GetFrecencyMinScore() != 1.5 {
+		t.Errorf("settings = depth %d, zoxide %v, recent %d, min score %v", cfg.SearchDepth, cfg.GetUseZoxide(), cfg.GetMaxRecent(), cfg.GetFrecencyMinScore())
+	}
+	if expected := []string{"target", "vendor"}; !reflect.DeepEqual(cfg.SearchExclude, expected) {
+		t.Errorf("SearchExclude = %v, want %v", cfg.SearchExclude, expected)
+	}
+	if len(cfg.SearchPaths) != 1 || cfg.SearchPaths[0].Label != "src" {
+		t.Errorf("SearchPaths = %v", cfg.SearchPaths)
+	}
+
+	expected := []WorkspaceConfig{
+		{
+			Directory: "/src/api",
+			Name:      "api",
+			Windows: []WindowConfig{
+				{Name: "editor", Command: "nvim", Focus: true},
+				{Name: "server", Env: map[string]string{"PORT": "8080"}},
+			},
+		},
+		{Directory: "/src/web", Name: "web", Hooks: HooksConfig{OnCreate: []string{"npm install"}}},
+	}
+	if !reflect.DeepEqual(cfg.Workspace, expected) {
+		t.Errorf("Workspace = %+v, want %+v", cfg.Workspace, expected)
+	}
+}
+
+func TestParseConfigFormatErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "search_depth: 2\nsearch_mode: [project]\n",
+		"b.yml":  "workspace:\n  - name: api\n    directory: /src/api\n  - name: web\n",
+		"c.json": "{\n  \"search_depth\": 2,\n  \"max_recent\": 5,,\n}\n",
+		"d.json": "{\n  \"max_recent\": \"ten\"\n}\n",
+		"e.yaml": "search_depth: 2\n  bad indentation: [\n",
+		"f.yaml": "profile:\n  laptop:\n    frecency_limit: -1\n",
+	})
+
+	l := parseConfigDir(dir, []string{"laptop"})
+	expected := []string{
+		"a.yaml:2:14: failed to decode YAML: search_mode: expected a string, found a list",
+		"b.yml:4:5: workspace directory cannot be empty",
+		"c.json:3:19: failed to decode JSON: invalid character ',' looking for beginning of object key string",
+		`d.json:2:17: failed to decode JSON: max_recent: expected an integer, found "ten"`,
+		"e.yaml:2: failed to decode YAML: yaml: line 2: mapping values are not allowed in this context",
+		"f.yaml:3:5: frecency_limit cannot be negative",
+	}
+	if got := findingStrings(dir, l.errors); !reflect.DeepEqual(got, expected) {
+		t.Errorf("errors =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestCheckDirUnknownKeysInYAML(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.yaml": "$schema: tmx.schema.json\nserch_depth: 2\nworkspace:\n  - name: api\n    directory: /src/api\n    windws: []\n",
+	})
+
+	result := CheckDir(dir, "")
+	expected := []string{
+		`tmx.yaml:2:1: unknown key "serch_depth"`,
+		`tmx.yaml:6:5: unknown key "workspace.0.windws"`,
+	}
+	got := findingStrings(dir, result.Errors)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("errors = %v, want %v", got, expected)
+	}
+}
+
+func TestConfigFilesFormats(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.json":   "{}",
+		"tmx.yaml": "",
+		"tmx.toml": "",
+		"b.yml":    "",
+		"c.txt":    "",
+	})
+
+	files, err := configFiles(dir)
+	if err != nil {
+		t.Fatalf("configFiles() error = %v", err)
+	}
+	if expected := []string{"tmx.toml", "tmx.yaml", "a.json", "b.yml"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("configFiles() = %v, want %v", files, expected)
+	}
+}
+
+// schemaObject is the part of a JSON Schema object definition compared against the config structs
+type schemaObject struct {
+	Properties  map[string]json.RawMessage `json:"properties"`
+	Definitions map[string]schemaObject    `json:"definitions"`
+}
+
+func TestSchemaMatchesConfig(t *testing.T) {
+	var root schemaObject
+	if err := json.Unmarshal(Schema, &root); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+
+	objects := []struct {
+		name   string
+		schema schemaObject
+		typ    reflect.Type
+		extra  []string
+	}{
+		{"root", root, reflect.TypeFor[Config](), []string{schemaKey}},
+		{"search_path", root.Definitions["search_path"], reflect.TypeFor[SearchPathConfig](), nil},
+		{"window", root.Definitions["window"], reflect.TypeFor[WindowConfig](), nil},
+		{"hooks", root.Definitions["hooks"], reflect.TypeFor[HooksConfig](), nil},
+		{"workspace", root.Definitions["workspace"], reflect.TypeFor[WorkspaceConfig](), nil},
+		{"template", root.Definitions["template"], reflect.TypeFor[TemplateConfig](), nil},
+	}
+
+	for _, obj := range objects {
+		var keys []string
+		for key := range tomlFields(obj.typ) {
+			keys = append(keys, key)
+		}
+		keys = append(keys, obj.extra...)
+
+		var properties []string
+		for key := range obj.schema.Properties {
+			properties = append(properties, key)
+		}
+
+		slices.Sort(keys)
+		slices.Sort(properties)
+		if !slices.Equal(keys, properties) {
+			t.Errorf("schema properties of %s = %v, want %v", obj.name, properties, keys)
+		}
+	}
+}
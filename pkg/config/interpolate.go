@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Vars are the values of the tmx variables available as {{.Dir}}, {{.Name}},
+// {{.Session}} and {{.GitBranch}} in workspace names, commands, hooks and env values
+type Vars struct {
+	Dir       string // Absolute path of the selected directory
+	Name      string // Rendered workspace name
+	Session   string // tmux session name
+	GitBranch string // Branch checked out in Dir, empty outside a git repository
+}
+
+// Variables available in workspace names; the session name is derived from the
+// workspace name, so neither can refer to itself
+var nameVars = []string{"Dir", "GitBranch"}
+
+// Variables available in window commands, hooks and env values
+var commandVars = []string{"Dir", "Name", "Session", "GitBranch"}
+
+// Interpolate replaces the {{.Var}} placeholders in s with vars. Strings without
+// placeholders are returned unchanged.
+func Interpolate(s string, vars Vars) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	return render(s, map[string]string{
+		"Dir":       vars.Dir,
+		"Name":      vars.Name,
+		"Session":   vars.Session,
+		"GitBranch": vars.GitBranch,
+	})
+}
+
+// render executes s as a template over data, failing on variables missing from data
+func render(s string, data map[string]string) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %w", s, err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("undefined variable in %q: %w", s, unwrapExecError(err))
+	}
+	return sb.String(), nil
+}
+
+// unwrapExecError drops the template location prefix from an execution error, which
+// is meaningless for single-line config values
+func unwrapExecError(err error) error {
+	if i := strings.LastIndex(err.Error(), ": "); i >= 0 {
+		return fmt.Errorf("%s", err.Error()[i+2:])
+	}
+	return err
+}
+
+// checkVars reports placeholders in s referring to variables outside allowed
+func checkVars(s string, allowed []string) error {
+	if !strings.Contains(s, "{{") {
+		return nil
+	}
+	data := make(map[string]string, len(allowed))
+	for _, name := range allowed {
+		data[name] = ""
+	}
+	if _, err := render(s, data); err != nil {
+		return fmt.Errorf(`%w (available: %s; write {{"{{"}} for a literal {{)`, err, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// envMode selects which environment references of a config value are expanded
+type envMode int
+
+const (
+	// envBraced expands ${VAR}, failing on variables that are not set
+	envBraced envMode = iota
+	// envBare expands $VAR as well as ${VAR}, failing on variables that are not set
+	envBare
+	// envShell expands ${VAR} for variables set when the config is loaded and leaves
+	// every other reference to the shell that runs the value
+	envShell
+)
+
+// expandEnv expands the environment references of s as selected by mode. ${VAR:-word}
+// and ${VAR-word} give a default for an empty or unset variable, except with envShell,
+// which leaves them to the shell.
+func expandEnv(s string, mode envMode) (string, error) {
+	var missing []string
+	var invalid error
+	lookup := func(ref string) string {
+		value, ok, err := lookupEnv(ref)
+		if err != nil && invalid == nil {
+			invalid = err
+		}
+		if err == nil && !ok && !slices.Contains(missing, ref) {
+			missing = append(missing, ref)
+		}
+		return value
+	}
+
+	var expanded string
+	switch mode {
+	case envBare:
+		expanded = os.Expand(s, lookup)
+	case envShell:
+		expanded = expandBraced(s, func(ref string) (string, bool) {
+			if !isEnvName(ref) {
+				return "", false
+			}
+			return os.LookupEnv(ref)
+		})
+	default:
+		expanded = expandBraced(s, func(ref string) (string, bool) { return lookup(ref), true })
+	}
+
+	if invalid != nil {
+		return "", fmt.Errorf("%w in %q", invalid, s)
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined environment variable %s in %q", strings.Join(missing, ", "), s)
+	}
+	return expanded, nil
+}
+
+// lookupEnv resolves the inside of an environment reference: a variable name, possibly
+// followed by :-word or -word. It reports false when the variable is unset and no default
+// applies, and an error for any other form.
+func lookupEnv(ref string) (string, bool, error) {
+	end := 0
+	for end < len(ref) && isEnvName(ref[:end+1]) {
+		end++
+	}
+	name, op := ref[:end], ref[end:]
+	if name == "" {
+		return "", false, fmt.Errorf("unsupported expansion ${%s}", ref)
+	}
+
+	value, ok := os.LookupEnv(name)
+	switch {
+	case op == "":
+		return value, ok, nil
+	case strings.HasPrefix(op, ":-"):
+		if value == "" {
+			return op[2:], true, nil
+		}
+		return value, true, nil
+	case strings.HasPrefix(op, "-"):
+		if !ok {
+			return op[1:], true, nil
+		}
+		return value, true, nil
+	}
+	return "", false, fmt.Errorf("unsupported expansion ${%s}", ref)
+}
+
+// isEnvName reports whether s is a valid environment variable name
+func isEnvName(s string) bool {
+	for i, r := range s {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && (i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// expandBraced expands only ${...} references in s, leaving $VAR and $(...) to the
+// shell that eventually runs s. References lookup reports false for are kept as they are.
+func expandBraced(s string, lookup func(string) (string, bool)) string {
+	var sb strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			break
+		}
+		sb.WriteString(s[:start])
+		if value, ok := lookup(s[start+2 : start+end]); ok {
+			sb.WriteString(value)
+		} else {
+			sb.WriteString(s[start : start+end+1])
+		}
+		s = s[start+end+1:]
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+// interpolateField expands the environment in a config value and validates its
+// {{.Var}} placeholders against allowed
+func interpolateField(field, value string, mode envMode, allowed []string) (string, error) {
+	expanded, err := expandEnv(value, mode)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	if err := checkVars(expanded, allowed); err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	return expanded, nil
+}
+
+// interpolateConfig interpolates every workspace and template of a single config file
+func interpolateConfig(config *Config) error {
+	for i := range config.Workspace {
+		if err := interpolateWorkspace(&config.Workspace[i]); err != nil {
+			return &tableError{table: "workspace", index: i, err: err}
+		}
+	}
+	for i := range config.Template {
+		if err := interpolateTemplate(&config.Template[i]); err != nil {
+			return &tableError{table: "template", index: i, err: err}
+		}
+	}
+	return nil
+}
+
+// interpolateWorkspace expands ~ and environment variables in ws and validates its
+// {{.Var}} placeholders, which are rendered when a session is created
+func interpolateWorkspace(ws *WorkspaceConfig) error {
+	var err error
+	if ws.Directory, err = interpolateField("directory", expandHome(ws.Directory), envBare, nil); err != nil {
+		return fmt.Errorf("workspace %q: %w", ws.Name, err)
+	}
+	if ws.Name, err = interpolateField("name", ws.Name, envBraced, nameVars); err != nil {
+		return fmt.Errorf("workspace %q: %w", ws.Name, err)
+	}
+	if err := interpolateCommands(ws.Windows, &ws.Hooks, ws.Env); err != nil {
+		return fmt.Errorf("workspace %q: %w", ws.Name, err)
+	}
+	if ws.EnvFile, err = interpolateField("env_file", expandHome(ws.EnvFile), envBare, nil); err != nil {
+		return fmt.Errorf("workspace %q: %w", ws.Name, err)
+	}
+	if ws.Socket, err = interpolateField("socket", expandHome(ws.Socket), envBare, nil); err != nil {
+		return fmt.Errorf("workspace %q: %w", ws.Name, err)
+	}
+	return nil
+}
+
+// interpolateTemplate expands environment variables in tpl and validates its placeholders
+func interpolateTemplate(tpl *TemplateConfig) error {
+	if err := interpolateCommands(tpl.Windows, &tpl.Hooks, tpl.Env); err != nil {
+		return fmt.Errorf("template %q: %w", tpl.Name, err)
+	}
+	var err error
+	if tpl.EnvFile, err = interpolateField("env_file", expandHome(tpl.EnvFile), envBare, nil); err != nil {
+		return fmt.Errorf("template %q: %w", tpl.Name, err)
+	}
+	return nil
+}
+
+// interpolateCommands expands environment variables in window commands, hooks and
+// env values in place. Commands and hooks run in a shell, so references to variables
+// that are not set yet are left for it.
+func interpolateCommands(windows []WindowConfig, hooks *HooksConfig, env map[string]string) error {
+	var err error
+	for i := range windows {
+		field := fmt.Sprintf("window %q command", windows[i].Name)
+		if windows[i].Command, err = interpolateField(field, windows[i].Command, envShell, commandVars); err != nil {
+			return err
+		}
+		field = fmt.Sprintf("window %q dir", windows[i].Name)
+		if windows[i].Dir, err = interpolateField(field, expandHome(windows[i].Dir), envBare, commandVars); err != nil {
+			return err
+		}
+		for name, value := range windows[i].Env {
+			field := fmt.Sprintf("window %q env %s", windows[i].Name, name)
+			if windows[i].Env[name], err = interpolateField(field, value, envBraced, commandVars); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range hooks.OnCreate {
+		if hooks.OnCreate[i], err = interpolateField("on_create hook", hooks.OnCreate[i], envShell, commandVars); err != nil {
+			return err
+		}
+	}
+	for name, value := range env {
+		if env[name], err = interpolateField("env "+name, value, envBraced, commandVars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
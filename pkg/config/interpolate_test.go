@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	vars := Vars{Dir: "/src/api", Name: "api", Session: "api", GitBranch: "main"}
+
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "nvim .", expected: "nvim ."},
+		{input: "cd {{.Dir}} && git switch {{.GitBranch}}", expected: "cd /src/api && git switch main"},
+		{input: "tmux rename-window -t {{.Session}} {{.Name}}", expected: "tmux rename-window -t api api"},
+		{input: "echo {{.Missing}}", wantErr: true},
+		{input: "echo {{.Dir", wantErr: true},
+		{input: `docker ps --format '{{"{{"}}.Names}}'`, expected: "docker ps --format '{{.Names}}'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := Interpolate(tt.input, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Interpolate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("Interpolate() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TMX_TEST_ROOT", "/src")
+	t.Setenv("TMX_TEST_EMPTY", "")
+	os.Unsetenv("TMX_TEST_UNSET")
+
+	tests := []struct {
+		input    string
+		mode     envMode
+		expected string
+		wantErr  bool
+	}{
+		{input: "${TMX_TEST_ROOT}/api", expected: "/src/api"},
+		{input: "$TMX_TEST_ROOT/api", mode: envBare, expected: "/src/api"},
+		// Without bare expansion, $VAR and $(...) are left to the shell
+		{input: "echo $TMX_TEST_ROOT $(pwd) ${TMX_TEST_ROOT}", expected: "echo $TMX_TEST_ROOT $(pwd) /src"},
+		{input: "${TMX_TEST_UNSET}/api", wantErr: true},
+		{input: "$TMX_TEST_UNSET/api", mode: envBare, wantErr: true},
+		// Defaults apply to unset variables, and with :- to empty ones too
+		{input: "${TMX_TEST_UNSET:-3000}", expected: "3000"},
+		{input: "${TMX_TEST_UNSET-3000}", mode: envBare, expected: "3000"},
+		{input: "${TMX_TEST_EMPTY:-3000}", expected: "3000"},
+		{input: "${TMX_TEST_EMPTY-3000}", expected: ""},
+		{input: "${TMX_TEST_ROOT:-/opt}/api", expected: "/src/api"},
+		{input: "${TMX_TEST_ROOT%/*}", wantErr: true},
+		// Commands leave to the shell what is not set when the config is loaded
+		{input: "echo ${TMX_TEST_ROOT} ${PORT:-3000} ${TMX_TEST_UNSET}", mode: envShell, expected: "echo /src ${PORT:-3000} ${TMX_TEST_UNSET}"},
+		{input: `for f in *.go; do echo "${f%.go}"; done`, mode: envShell, expected: `for f in *.go; do echo "${f%.go}"; done`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := expandEnv(tt.input, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("expandEnv() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseConfigInterpolation(t *testing.T) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	t.Setenv("TMX_TEST_PROFILE", "staging")
+
+	t.Run("Expanded", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tomlData := `
+[[workspace]]
+directory = "~/Git/api"
+name = "api-{{.GitBranch}}"
+windows = [
+  {name = "deploy", command = "deploy --profile ${TMX_TEST_PROFILE} --dir {{.Dir}}"},
+  {name = "serve", command = "PORT=${PORT:-3000} make serve"},
+  {name = "loop", command = "for f in *.log; do tail \"${f}\"; done"},
+  {name = "docker", command = "docker ps --format '{{\"{{\"}}.Names}}'"},
+]
+hooks = {on_create = ["echo ${TMX_TEST_UNDEFINED_HOOK_VAR}"]}
+env = {PROFILE = "${TMX_TEST_PROFILE}", PORT = "${TMX_TEST_UNDEFINED_PORT:-3000}"}
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tomlData), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, errors := parseConfigFile(tmpDir)
+		if len(errors) > 0 {
+			t.Fatalf("expected no errors, got: %v", errors)
+		}
+
+		ws := cfg.Workspace[0]
+		if ws.Directory != filepath.Join(homeDir, "Git/api") {
+			t.Errorf("Directory = %q, want ~ expanded", ws.Directory)
+		}
+		// tmx variables are kept until a session is created
+		if ws.Name != "api-{{.GitBranch}}" {
+			t.Errorf("Name = %q, want placeholders kept", ws.Name)
+		}
+		if ws.Windows[0].Command != "deploy --profile staging --dir {{.Dir}}" {
+			t.Errorf("Command = %q, want env expanded and placeholders kept", ws.Windows[0].Command)
+		}
+		// References the shell resolves at run time are kept
+		if ws.Windows[1].Command != "PORT=${PORT:-3000} make serve" {
+			t.Errorf("Command = %q, want ${PORT:-3000} kept", ws.Windows[1].Command)
+		}
+		if ws.Windows[2].Command != `for f in *.log; do tail "${f}"; done` {
+			t.Errorf("Command = %q, want ${f} kept", ws.Windows[2].Command)
+		}
+		if ws.Hooks.OnCreate[0] != "echo ${TMX_TEST_UNDEFINED_HOOK_VAR}" {
+			t.Errorf("Hook = %q, want the unset variable kept", ws.Hooks.OnCreate[0])
+		}
+		docker, err := Interpolate(ws.Windows[3].Command, Vars{})
+		if err != nil || docker != "docker ps --format '{{.Names}}'" {
+			t.Errorf("Interpolate(%q) = %q, %v, want a literal {{", ws.Windows[3].Command, docker, err)
+		}
+		if ws.Env["PROFILE"] != "staging" || ws.Env["PORT"] != "3000" {
+			t.Errorf("Env = %v, want PROFILE=staging and PORT=3000", ws.Env)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		toml     string
+		contains string
+	}{
+		{
+			name: "UndefinedEnv",
+			toml: `
+[[workspace]]
+directory = "$TMX_TEST_UNDEFINED/api"
+name = "api"
+`,
+			contains: "undefined environment variable TMX_TEST_UNDEFINED",
+		},
+		{
+			name: "UndefinedVariable",
+			toml: `
+[[workspace]]
+directory = "/src/api"
+name = "api"
+windows = [{name = "a", command = "echo {{.Branch}}"}]
+`,
+			contains: `window "a" command: undefined variable`,
+		},
+		{
+			name: "UndefinedEnvValue",
+			toml: `
+[[workspace]]
+directory = "/src/api"
+name = "api"
+env = {TOKEN = "${TMX_TEST_UNDEFINED}"}
+`,
+			contains: "env TOKEN: undefined environment variable TMX_TEST_UNDEFINED",
+		},
+		{
+			name: "UnescapedBraces",
+			toml: `
+[[workspace]]
+directory = "/src/api"
+name = "api"
+windows = [{name = "docker", command = "docker ps --format '{{.Names}}'"}]
+`,
+			contains: `write {{"{{"}} for a literal {{`,
+		},
+		{
+			name: "SessionInName",
+			toml: `
+[[workspace]]
+directory = "/src/api"
+name = "{{.Session}}"
+`,
+			contains: "available: Dir, GitBranch",
+		},
+		{
+			name: "TemplateHook",
+			toml: `
+[[template]]
+name = "base"
+hooks = {on_create = ["echo {{.Nope}}"]}
+`,
+			contains: `template "base": on_create hook`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tt.toml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, errors := parseConfigFile(tmpDir)
+			if len(errors) != 1 {
+				t.Fatalf("expected 1 error, got: %v", errors)
+			}
+			if !strings.Contains(errors[0].Error.Error(), tt.contains) {
+				t.Errorf("expected error containing %q, got: %v", tt.contains, errors[0].Error)
+			}
+		})
+	}
+}
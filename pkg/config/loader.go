@@ -0,0 +1,258 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// loader merges config files into a single Config, following their includes and
+// applying the sections of the active profile
+type loader struct {
+	dir      string   // Config directory; files inside it are named relative to it
+	profiles []string // Candidate names of the active profile, in order of preference
+
+	config           *Config
+	files            []string // Files merged so far, in order
+	workspaceOrigins []origin
+	templateOrigins  []origin
+	errors           []ConfigError
+
+	loaded       map[string]bool   // Absolute paths of the files already merged
+	usedProfiles map[string]bool   // Profiles found in at least one file
+	fileProfiles map[string]string // Profile applied to each file, if any
+}
+
+// parseConfigDir reads and merges all TOML files in the given directory, applying the
+// first of profiles that each file defines
+func parseConfigDir(path string, profiles []string) *loader {
+	l := &loader{
+		dir:          path,
+		profiles:     profiles,
+		config:       &Config{Workspace: []WorkspaceConfig{}},
+		loaded:       make(map[string]bool),
+		usedProfiles: make(map[string]bool),
+		fileProfiles: make(map[string]string),
+	}
+
+	// Ensure the config directory exists
+	if err := ensureConfigDir(path); err != nil {
+		l.errors = append(l.errors, ConfigError{File: path, Error: err})
+		return l
+	}
+
+	files, err := configFiles(path)
+	if err != nil {
+		l.errors = append(l.errors, ConfigError{File: path, Error: err})
+		return l
+	}
+
+	for _, name := range files {
+		l.loadFile(name)
+	}
+
+	// Templates may live in any file, so workspaces are resolved once all are loaded
+	var resolveErrors []ConfigError
+	l.workspaceOrigins, resolveErrors = resolveWorkspaces(path, l.config, l.workspaceOrigins, l.templateOrigins)
+	l.errors = append(l.errors, resolveErrors...)
+
+	return l
+}
+
+// loadFile merges the file called name, then the active profile section in it, then the
+// files it includes. Files that were already merged are skipped, which also breaks
+// include cycles.
+func (l *loader) loadFile(name string) {
+	filePath := configPath(l.dir, name)
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+	if l.loaded[filePath] {
+		return
+	}
+	l.loaded[filePath] = true
+	l.files = append(l.files, name)
+
+	tempConfig, err := parseSingleConfigFile(filePath)
+	if err != nil {
+		l.errors = append(l.errors, newConfigError(l.dir, name, err))
+		return
+	}
+
+	includes := tempConfig.Include
+	if !l.merge(name, "", tempConfig) {
+		return
+	}
+
+	// The active profile is merged right after the rest of the file, so it wins over it
+	if profile, section, ok := l.activeProfile(tempConfig); ok {
+		l.fileProfiles[name] = profile
+		if l.merge(name, profileTable(profile), &section) {
+			includes = append(includes, section.Include...)
+		}
+	}
+
+	for _, pattern := range includes {
+		matches, err := expandInclude(filepath.Dir(filePath), pattern)
+		if err != nil {
+			l.errors = append(l.errors, newConfigError(l.dir, name, &keyError{key: "include", err: err}))
+			continue
+		}
+		for _, match := range matches {
+			l.loadFile(l.relativeName(match))
+		}
+	}
+}
+
+// merge validates cfg, read from the file called name, and merges it into the config.
+// table is empty for the top level of the file, or the table cfg was read from followed
+// by a dot, such as "profile.laptop.". It reports whether cfg was valid.
+func (l *loader) merge(name, table string, cfg *Config) bool {
+	if table != "" && len(cfg.Profile) > 0 {
+		err := &keyError{key: table + "profile", err: fmt.Errorf("profiles cannot be nested")}
+		l.errors = append(l.errors, newConfigError(l.dir, name, err))
+		return false
+	}
+
+	if err := validateConfigFile(cfg); err != nil {
+		l.errors = append(l.errors, newConfigError(l.dir, name, prefixError(table, err)))
+		return false
+	}
+
+	if err := mergeSettings(l.config, cfg); err != nil {
+		l.errors = append(l.errors, newConfigError(l.dir, name, prefixError(table, err)))
+	}
+
+	// Merge workspace configurations, rejecting conflicts unless marked as overrides
+	for i, ws := range cfg.Workspace {
+		wsOrigin := origin{file: name, table: table + "workspace", index: i}
+		var err error
+		l.config.Workspace, l.workspaceOrigins, err = mergeWorkspace(l.dir, l.config.Workspace, l.workspaceOrigins, ws, wsOrigin)
+		if err != nil {
+			l.errors = append(l.errors, newConfigError(l.dir, name, wsOrigin.wrap(err)))
+		}
+	}
+
+	// Append templates
+	l.config.Template = append(l.config.Template, cfg.Template...)
+	for i := range cfg.Template {
+		l.templateOrigins = append(l.templateOrigins, origin{file: name, table: table + "template", index: i})
+	}
+
+	return true
+}
+
+// activeProfile returns the first profile candidate defined in cfg
+func (l *loader) activeProfile(cfg *Config) (string, Config, bool) {
+	for _, name := range l.profiles {
+		if section, ok := cfg.Profile[name]; ok {
+			l.usedProfiles[name] = true
+			return name, section, true
+		}
+	}
+	return "", Config{}, false
+}
+
+// relativeName returns the name of the file at path relative to the config directory,
+// or path itself when the file lives elsewhere
+func (l *loader) relativeName(path string) string {
+	dir, err := filepath.Abs(l.dir)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// profileTable returns the prefix of the keys and tables of the named profile
+func profileTable(name string) string {
+	return "profile." + name + "."
+}
+
+// expandInclude returns the files matched by an include pattern of a file in dir. The
+// pattern may start with ~ and contain environment variables and glob wildcards; relative
+// patterns are resolved against dir. A pattern without wildcards must match an existing file.
+func expandInclude(dir, pattern string) ([]string, error) {
+	path, err := expandEnv(expandHome(pattern), envBare)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	if !strings.ContainsAny(path, "*?[") {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("included file %s does not exist", pattern)
+		}
+		return []string{path}, nil
+	}
+
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// prefixError moves the location of err into the given table, so that errors found in
+// a profile section point at the section rather than the top level of the file
+func prefixError(table string, err error) error {
+	if table == "" {
+		return err
+	}
+	switch e := err.(type) {
+	case *tableError:
+		return &tableError{table: table + e.table, index: e.index, err: e.err}
+	case *keyError:
+		return &keyError{key: table + e.key, err: e.err}
+	}
+	return err
+}
+
+// configPath returns the path of the config file called name, which is either relative
+// to the config directory dir or absolute for files included from elsewhere
+func configPath(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// profileCandidates returns the names of the profile to apply, in order of preference,
+// and whether the profile was chosen explicitly. The profile flag wins over the
+// TMX_PROFILE environment variable, which wins over the hostname.
+func profileCandidates(flag string) ([]string, bool) {
+	if flag != "" {
+		return []string{flag}, true
+	}
+	if env := os.Getenv("TMX_PROFILE"); env != "" {
+		return []string{env}, true
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return nil, false
+	}
+	candidates := []string{hostname}
+	if short, _, found := strings.Cut(hostname, "."); found && short != "" {
+		candidates = append(candidates, short)
+	}
+	return candidates, false
+}
+
+// loadConfig merges the config files in path with the profile selected by flag, the
+// environment or the hostname applied. A profile chosen explicitly must be defined.
+func loadConfig(path, flag string) *loader {
+	profiles, explicit := profileCandidates(flag)
+	l := parseConfigDir(path, profiles)
+	if explicit && !l.usedProfiles[profiles[0]] {
+		l.errors = append(l.errors, ConfigError{File: path, Error: fmt.Errorf("profile %q is not defined in any config file", profiles[0])})
+	}
+	return l
+}
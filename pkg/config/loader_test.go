@@ -0,0 +1,223 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigIncludes(t *testing.T) {
+	shared := t.TempDir()
+	for name, content := range map[string]string{
+		"a.toml": "search_depth = 4\n\n[[workspace]]\ndirectory = \"/src/a\"\nname = \"a\"\n",
+		// Including the main file again must not merge it twice
+		"b.toml": "include = [\"a.toml\"]\n\n[[workspace]]\ndirectory = \"/src/b\"\nname = \"b\"\n",
+	} {
+		if err := os.WriteFile(filepath.Join(shared, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "include = [\"local/extra.toml\", \"" + shared + "/*.toml\"]\nsearch_depth = 2\n",
+	})
+	if err := os.MkdirAll(filepath.Join(dir, "local"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	extra := "include = [\"../tmx.toml\"]\n\n[[workspace]]\ndirectory = \"/src/extra\"\nname = \"extra\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "local", "extra.toml"), []byte(extra), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := parseConfigDir(dir, nil)
+	if len(l.errors) > 0 {
+		t.Fatalf("expected no errors, got: %v", l.errors)
+	}
+
+	var names []string
+	for _, ws := range l.config.Workspace {
+		names = append(names, ws.Name)
+	}
+	if expected := []string{"extra", "a", "b"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("workspaces = %v, want %v", names, expected)
+	}
+
+	// Included files are merged after the file including them
+	if l.config.SearchDepth != 4 {
+		t.Errorf("SearchDepth = %d, want 4", l.config.SearchDepth)
+	}
+
+	expectedFiles := []string{"tmx.toml", filepath.Join("local", "extra.toml"), filepath.Join(shared, "a.toml"), filepath.Join(shared, "b.toml")}
+	if !reflect.DeepEqual(l.files, expectedFiles) {
+		t.Errorf("files = %v, want %v", l.files, expectedFiles)
+	}
+}
+
+func TestParseConfigIncludeErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "search_depth = 2\ninclude = [\"missing.toml\", \"none/*.toml\"]\n",
+	})
+
+	_, errors := parseConfigFile(dir)
+	expected := []string{"tmx.toml:2:1: included file missing.toml does not exist"}
+	if got := findingStrings(dir, errors); !reflect.DeepEqual(got, expected) {
+		t.Errorf("errors = %v, want %v", got, expected)
+	}
+}
+
+func TestParseConfigProfiles(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": `search_depth = 2
+search_exclude = ["node_modules"]
+
+[[workspace]]
+directory = "/src/api"
+name = "api"
+
+[profile.laptop]
+search_depth = 1
+search_exclude = ["vendor"]
+
+[[profile.laptop.workspace]]
+directory = "/src/dotfiles"
+name = "dotfiles"
+
+[profile.work]
+search_depth = 5
+
+[[profile.work.workspace]]
+directory = "/work/api"
+name = "api"
+override = true
+`,
+	})
+
+	tests := []struct {
+		name       string
+		profiles   []string
+		depth      int
+		workspaces []string
+	}{
+		{"no profile", nil, 2, []string{"api"}},
+		{"unknown profile", []string{"desktop"}, 2, []string{"api"}},
+		{"laptop", []string{"laptop"}, 1, []string{"api", "dotfiles"}},
+		{"first defined candidate wins", []string{"desktop", "work", "laptop"}, 5, []string{"api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseConfigDir(dir, tt.profiles)
+			if len(l.errors) > 0 {
+				t.Fatalf("expected no errors, got: %v", l.errors)
+			}
+			if l.config.SearchDepth != tt.depth {
+				t.Errorf("SearchDepth = %d, want %d", l.config.SearchDepth, tt.depth)
+			}
+
+			var names []string
+			for _, ws := range l.config.Workspace {
+				names = append(names, ws.Name)
+			}
+			if !reflect.DeepEqual(names, tt.workspaces) {
+				t.Errorf("workspaces = %v, want %v", names, tt.workspaces)
+			}
+		})
+	}
+
+	l := parseConfigDir(dir, []string{"work"})
+	if l.config.Workspace[0].Directory != "/work/api" {
+		t.Errorf("profile workspace should override the top-level one, got %s", l.config.Workspace[0].Directory)
+	}
+
+	l = parseConfigDir(dir, []string{"laptop"})
+	if expected := []string{"node_modules", "vendor"}; !reflect.DeepEqual(l.config.SearchExclude, expected) {
+		t.Errorf("SearchExclude = %v, want %v", l.config.SearchExclude, expected)
+	}
+}
+
+func TestParseConfigProfileResetsToZero(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "frecency_min_score = 2.5\n\n[profile.laptop]\nfrecency_min_score = 0\n",
+	})
+
+	if l := parseConfigDir(dir, nil); l.config.GetFrecencyMinScore() != 2.5 {
+		t.Errorf("FrecencyMinScore = %v, want 2.5", l.config.GetFrecencyMinScore())
+	}
+	if l := parseConfigDir(dir, []string{"laptop"}); l.config.GetFrecencyMinScore() != 0 {
+		t.Errorf("FrecencyMinScore with the laptop profile = %v, want 0", l.config.GetFrecencyMinScore())
+	}
+}
+
+func TestParseConfigProfileErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": `frecency_limit = 10
+
+[profile.laptop]
+frecency_limit = -1
+
+[[profile.laptop.workspace]]
+name = "dotfiles"
+
+[profile.work.profile.nested]
+search_depth = 1
+`,
+	})
+
+	l := parseConfigDir(dir, []string{"laptop"})
+	expected := []string{"tmx.toml:6:1: workspace directory cannot be empty"}
+	if got := findingStrings(dir, l.errors); !reflect.DeepEqual(got, expected) {
+		t.Errorf("errors = %v, want %v", got, expected)
+	}
+
+	l = parseConfigDir(dir, []string{"work"})
+	if len(l.errors) != 1 || !strings.Contains(l.errors[0].Error.Error(), "profiles cannot be nested") {
+		t.Errorf("expected a nested profile error, got %v", l.errors)
+	}
+
+	// Keys of a profile are located below its header rather than at the top level
+	dir = writeConfigFiles(t, map[string]string{
+		"tmx.toml": "frecency_limit = 10\n\n[profile.laptop]\n  frecency_limit = -1\n",
+	})
+	l = parseConfigDir(dir, []string{"laptop"})
+	expected = []string{"tmx.toml:4:3: frecency_limit cannot be negative"}
+	if got := findingStrings(dir, l.errors); !reflect.DeepEqual(got, expected) {
+		t.Errorf("errors = %v, want %v", got, expected)
+	}
+}
+
+func TestProfileCandidates(t *testing.T) {
+	t.Setenv("TMX_PROFILE", "work")
+
+	if profiles, explicit := profileCandidates("laptop"); !reflect.DeepEqual(profiles, []string{"laptop"}) || !explicit {
+		t.Errorf("profileCandidates(laptop) = %v, %v, want [laptop], true", profiles, explicit)
+	}
+	if profiles, explicit := profileCandidates(""); !reflect.DeepEqual(profiles, []string{"work"}) || !explicit {
+		t.Errorf("profileCandidates() = %v, %v, want [work], true", profiles, explicit)
+	}
+
+	t.Setenv("TMX_PROFILE", "")
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip("hostname not available")
+	}
+	if profiles, explicit := profileCandidates(""); len(profiles) == 0 || profiles[0] != hostname || explicit {
+		t.Errorf("profileCandidates() = %v, %v, want the hostname first", profiles, explicit)
+	}
+}
+
+func TestLoadConfigUndefinedProfile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "[profile.laptop]\nsearch_depth = 1\n",
+	})
+
+	if l := loadConfig(dir, "laptop"); len(l.errors) > 0 {
+		t.Errorf("expected no errors, got: %v", l.errors)
+	}
+
+	l := loadConfig(dir, "desktop")
+	if len(l.errors) != 1 || !strings.Contains(l.errors[0].Error.Error(), `profile "desktop" is not defined`) {
+		t.Errorf("expected an undefined profile error, got %v", l.errors)
+	}
+}
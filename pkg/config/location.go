@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tableError is an error about one entry of an array of tables, such as the second
+// [[workspace]] of a file
+type tableError struct {
+	table string
+	index int
+	err   error
+}
+
+func (e *tableError) Error() string { return e.err.Error() }
+func (e *tableError) Unwrap() error { return e.err }
+
+// keyError is an error about the value of a top-level key
+type keyError struct {
+	key string
+	err error
+}
+
+func (e *keyError) Error() string { return e.err.Error() }
+func (e *keyError) Unwrap() error { return e.err }
+
+// typeErrorPattern matches the position given in TOML type mismatch errors
+var typeErrorPattern = regexp.MustCompile(`toml: line (\d+) \(last key "([^"]*)"\)`)
+
+// newConfigError creates a ConfigError for the file called name in dir, locating the
+// line and column the error refers to when possible
+func newConfigError(dir, name string, err error) ConfigError {
+	configErr := ConfigError{File: name, Error: err}
+
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		configErr.Line, configErr.Column = parseErr.Position.Line, parseErr.Position.Col
+		return configErr
+	}
+
+	var posErr *positionError
+	if errors.As(err, &posErr) {
+		configErr.Line, configErr.Column = posErr.line, posErr.col
+		return configErr
+	}
+
+	var tableErr *tableError
+	var keyErr *keyError
+	switch {
+	case typeErrorPattern.MatchString(err.Error()):
+		// Type mismatches are plain errors that only mention the line in their message
+		m := typeErrorPattern.FindStringSubmatch(err.Error())
+		configErr.Line, _ = strconv.Atoi(m[1])
+		if content, readErr := os.ReadFile(configPath(dir, name)); readErr == nil {
+			configErr.Column = locateKeyOnLine(string(content), configErr.Line, m[2])
+		}
+	case errors.As(err, &tableErr):
+		configErr.Line, configErr.Column = locateEntry(dir, origin{file: name, table: tableErr.table, index: tableErr.index})
+	case errors.As(err, &keyErr):
+		if content, readErr := os.ReadFile(configPath(dir, name)); readErr == nil {
+			if fileFormat(name) == formatTOML {
+				configErr.Line, configErr.Column = locateTableKey(string(content), keyErr.key)
+			} else {
+				configErr.Line, configErr.Column = locateNode(content, strings.Split(keyErr.key, "."))
+			}
+		}
+	}
+	return configErr
+}
+
+// locateEntry returns the position of the [[table]] entry defined at o, or zeros when
+// it cannot be found
+func locateEntry(dir string, o origin) (line, col int) {
+	content, err := os.ReadFile(configPath(dir, o.file))
+	if err != nil {
+		return 0, 0
+	}
+	if fileFormat(o.file) != formatTOML {
+		return locateNode(content, append(strings.Split(o.table, "."), strconv.Itoa(o.index)))
+	}
+	return locateTable(string(content), o.table, o.index)
+}
+
+// Location returns where the error occurred as "file:line:column", leaving out the
+// line and column when they are unknown
+func (e ConfigError) Location() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	return e.File
+}
+
+// String formats the error as "file:line:column: message"
+func (e ConfigError) String() string {
+	return fmt.Sprintf("%s: %v", e.Location(), e.Error)
+}
+
+// locateTable returns the position of the header of the index-th [[table]] in content,
+// or zeros when it is not found
+func locateTable(content, table string, index int) (line, col int) {
+	header := regexp.MustCompile(`^(\s*)\[\[\s*` + regexp.QuoteMeta(table) + `\s*\]\]`)
+	for i, text := range strings.Split(content, "\n") {
+		m := header.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		if index == 0 {
+			return i + 1, len(m[1]) + 1
+		}
+		index--
+	}
+	return 0, 0
+}
+
+// locateKeyOnLine returns the column of the assignment to the dotted key on the given
+// line of content, or 1 when it cannot be found
+func locateKeyOnLine(content string, line int, key string) int {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return 1
+	}
+	parts := strings.Split(key, ".")
+	if _, col := locateKey(lines[line-1], parts[len(parts)-1]); col > 0 {
+		return col
+	}
+	return 1
+}
+
+// locateTableKey returns the position of the assignment to a dotted key such as
+// "profile.laptop.search_depth", looking below the [profile.laptop] header when there
+// is one, or zeros when it is not found
+func locateTableKey(content, key string) (line, col int) {
+	table, name := "", key
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		table, name = key[:i], key[i+1:]
+	}
+	if table == "" {
+		return locateKey(content, name)
+	}
+
+	header := regexp.MustCompile(`^\s*\[\s*` + regexp.QuoteMeta(table) + `\s*\]`)
+	lines := strings.Split(content, "\n")
+	for i, text := range lines {
+		if !header.MatchString(text) {
+			continue
+		}
+		if line, col := locateKey(strings.Join(lines[i+1:], "\n"), name); line > 0 {
+			return i + 1 + line, col
+		}
+		return i + 1, 1
+	}
+	return locateKey(content, name)
+}
+
+// locateKey returns the position of the first assignment to key in content, where key
+// is the last part of a dotted key path, or zeros when it is not found
+func locateKey(content, key string) (line, col int) {
+	assignment := regexp.MustCompile(`(^|[\s{,])(` + regexp.QuoteMeta(key) + `|"` + regexp.QuoteMeta(key) + `")\s*=`)
+	for i, text := range strings.Split(content, "\n") {
+		if loc := assignment.FindStringSubmatchIndex(text); loc != nil {
+			return i + 1, loc[4] + 1
+		}
+	}
+	return 0, 0
+}
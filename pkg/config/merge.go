@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// mainConfigFile is merged first; files merged later take precedence over it
+const mainConfigFile = "tmx.toml"
+
+// mainConfigName is the name, without extension, of the files merged first. tmx.toml
+// comes first, tmx.yaml, tmx.yml and tmx.json are accepted too.
+const mainConfigName = "tmx"
+
+// dropInDir holds additional config files merged after all the others
+const dropInDir = "conf.d"
+
+// configFiles returns the TOML, YAML and JSON files of the config directory relative to
+// it, in the order they are merged: tmx.toml, then the other main files (tmx.json,
+// tmx.yaml, ...), then any other file in the directory, then the files in conf.d/. Files
+// are sorted by name within each group. Later files win for settings that take a single
+// value.
+func configFiles(path string) ([]string, error) {
+	files, err := configFileNames(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The main files always come first, tmx.toml before the others
+	slices.SortStableFunc(files, func(a, b string) int {
+		return mainFileRank(a) - mainFileRank(b)
+	})
+
+	dropIns, err := configFileNames(filepath.Join(path, dropInDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, name := range dropIns {
+		files = append(files, filepath.Join(dropInDir, name))
+	}
+
+	return files, nil
+}
+
+// mainFileRank orders tmx.toml before the other main files, and those before the rest
+func mainFileRank(name string) int {
+	switch {
+	case name == mainConfigFile:
+		return 0
+	case strings.TrimSuffix(name, filepath.Ext(name)) == mainConfigName:
+		return 1
+	}
+	return 2
+}
+
+// configFileNames returns the names of the visible config files in dir, sorted by name
+func configFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		// Skip files in other formats and hidden files
+		if entry.IsDir() || fileFormat(entry.Name()) == "" || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// mergeWorkspace adds ws, defined at o, to the workspaces merged so far. A workspace with
+// the same name or directory as an earlier one is a conflict and is rejected, unless it
+// sets override, in which case it replaces the earlier one in place.
+func mergeWorkspace(dir string, workspaces []WorkspaceConfig, origins []origin, ws WorkspaceConfig, o origin) ([]WorkspaceConfig, []origin, error) {
+	i := slices.IndexFunc(workspaces, func(other WorkspaceConfig) bool { return other.Name == ws.Name })
+	reason := "name"
+	if i < 0 {
+		i = slices.IndexFunc(workspaces, func(other WorkspaceConfig) bool {
+			return filepath.Clean(other.Directory) == filepath.Clean(ws.Directory)
+		})
+		reason = "directory"
+	}
+
+	switch {
+	case i < 0:
+		return append(workspaces, ws), append(origins, o), nil
+	case ws.Override:
+		workspaces[i] = ws
+		origins[i] = o
+		return workspaces, origins, nil
+	}
+
+	line, col := locateEntry(dir, origins[i])
+	at := ConfigError{File: origins[i].file, Line: line, Column: col}.Location()
+	return workspaces, origins, fmt.Errorf("workspace %q has the same %s as workspace %q at %s; set override = true to replace it",
+		ws.Name, reason, workspaces[i].Name, at)
+}
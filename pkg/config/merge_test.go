@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigFilesOrder(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml":     "",
+		"a.toml":       "",
+		"z.toml":       "",
+		".hidden.toml": "",
+		"notes.txt":    "",
+	})
+	if err := os.MkdirAll(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"20-work.toml", "10-base.toml"} {
+		if err := os.WriteFile(filepath.Join(dir, "conf.d", name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := configFiles(dir)
+	if err != nil {
+		t.Fatalf("configFiles() error = %v", err)
+	}
+
+	expected := []string{"tmx.toml", "a.toml", "z.toml", "conf.d/10-base.toml", "conf.d/20-work.toml"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("configFiles() = %v, want %v", files, expected)
+	}
+}
+
+func TestParseConfigPrecedence(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "search_depth = 1\nmax_recent = 5\n",
+		"a.toml":   "search_depth = 2\n",
+	})
+	if err := os.MkdirAll(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "conf.d", "10-host.toml"), []byte("search_depth = 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, errors := parseConfigFile(dir)
+	if len(errors) > 0 {
+		t.Fatalf("expected no errors, got: %v", errors)
+	}
+
+	// conf.d is merged last, and tmx.toml first even though a.toml sorts before it
+	if cfg.SearchDepth != 3 || cfg.GetMaxRecent() != 5 {
+		t.Errorf("SearchDepth = %d, MaxRecent = %d, want 3 and 5", cfg.SearchDepth, cfg.GetMaxRecent())
+	}
+}
+
+func TestParseConfigWorkspaceConflicts(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": `
+[[workspace]]
+directory = "/src/api"
+name = "api"
+windows = [{name = "editor"}]
+
+[[workspace]]
+directory = "/src/web"
+name = "web"
+`,
+		"work.toml": `
+[[workspace]]
+directory = "/work/api"
+name = "api"
+
+[[workspace]]
+directory = "/src/web"
+name = "frontend"
+
+[[workspace]]
+directory = "/src/api"
+name = "api-v2"
+windows = [{name = "server"}]
+override = true
+`,
+	})
+
+	cfg, errors := parseConfigFile(dir)
+
+	expectedErrors := []string{
+		`work.toml:2:1: workspace "api" has the same name as workspace "api" at tmx.toml:2:1; set override = true to replace it`,
+		`work.toml:6:1: workspace "frontend" has the same directory as workspace "web" at tmx.toml:7:1; set override = true to replace it`,
+	}
+	var got []string
+	for _, e := range errors {
+		got = append(got, e.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(expectedErrors, "\n") {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expectedErrors, "\n"))
+	}
+
+	// The override replaces the workspace for /src/api in place; the conflicts are dropped
+	var names []string
+	for _, ws := range cfg.Workspace {
+		names = append(names, ws.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"api-v2", "web"}) {
+		t.Errorf("workspaces = %v, want [api-v2 web]", names)
+	}
+	if cfg.Workspace[0].Windows[0].Name != "server" {
+		t.Errorf("expected the overriding workspace's windows, got %+v", cfg.Workspace[0].Windows)
+	}
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// starterConfig is the commented tmx.toml written by InitConfig. Every setting is
+// commented out, so the defaults apply until the user opts in.
+const starterConfig = `# tmx configuration
+#
+# Uncomment and adjust the settings you need; the values shown are the defaults.
+# Every .toml, .yaml, .yml and .json file in this directory is merged, tmx.toml first,
+# then the other files by name, then the files in conf.d by name.
+# Run ` + "`tmx config check`" + ` after editing to validate the configuration.
+
+# How deep the directory search goes (0 = unlimited)
+# search_depth = 1
+
+# "directory" lists every directory, "project" only directories containing a marker
+# search_mode = "directory"
+# project_markers = [".git", "go.mod", "package.json", "Cargo.toml", ".tmx.toml"]
+
+# Gitignore-style patterns skipped during the search
+# search_exclude = ["target", ".venv"]
+
+# Suggest frecent directories from zoxide (or autojump, fasd, z) at the top of the list
+# use_zoxide = true
+# frecency_source = "zoxide"
+
+# Name sessions for git repositories repo/branch
+# worktree_mode = false
+
+# Directories searched when tmx is run without a path (default: your home directory)
+# [[search_paths]]
+# path = "~/Git"
+# depth = 2
+# label = "git"
+
+# Workspaces set up windows for sessions in a given directory.
+# Run ` + "`tmx workspace add [dir]`" + ` to add one interactively.
+# [[workspace]]
+# directory = "~/Git/api"
+# name = "api"
+# windows = [
+#   {name = "editor", command = "nvim"},
+#   {name = "test", command = "go test ./..."},
+# ]
+`
+
+// markerWindows maps project marker files to the window a workspace for such a
+// project most likely needs
+var markerWindows = []struct {
+	marker string
+	window WindowConfig
+}{
+	{"go.mod", WindowConfig{Name: "test", Command: "go test ./..."}},
+	{"Cargo.toml", WindowConfig{Name: "test", Command: "cargo test"}},
+	{"pyproject.toml", WindowConfig{Name: "test", Command: "pytest"}},
+	{"package.json", WindowConfig{Name: "dev", Command: "npm run dev"}},
+	{"Makefile", WindowConfig{Name: "build", Command: "make"}},
+	{"docker-compose.yml", WindowConfig{Name: "services", Command: "docker compose up"}},
+	{"compose.yaml", WindowConfig{Name: "services", Command: "docker compose up"}},
+}
+
+// InitConfig writes a commented starter tmx.toml to the configuration directory and
+// returns its path. An existing tmx.toml is only replaced when force is set.
+func InitConfig(force bool) (string, error) {
+	path, err := getPath()
+	if err != nil {
+		return "", err
+	}
+	return initConfigDir(path, force)
+}
+
+// initConfigDir writes the starter tmx.toml to the directory path
+func initConfigDir(path string, force bool) (string, error) {
+	if err := ensureConfigDir(path); err != nil {
+		return "", err
+	}
+
+	file := filepath.Join(path, mainConfigFile)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(file, flags, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return file, fmt.Errorf("%s: %w", file, os.ErrExist)
+		}
+		return file, err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(starterConfig); err != nil {
+		return file, err
+	}
+	return file, f.Close()
+}
+
+// InferWorkspace suggests a workspace for dir, named after it, with an editor window
+// (or a plain shell when $EDITOR is not set) followed by windows inferred from the
+// project markers found in dir
+func InferWorkspace(dir string) WorkspaceConfig {
+	first := WindowConfig{Name: "shell"}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		first = WindowConfig{Name: "editor", Command: editor}
+	}
+	ws := WorkspaceConfig{
+		Directory: dir,
+		Name:      filepath.Base(dir),
+		Windows:   []WindowConfig{first},
+	}
+
+	for _, mw := range markerWindows {
+		if _, err := os.Stat(filepath.Join(dir, mw.marker)); err != nil {
+			continue
+		}
+		if slices.ContainsFunc(ws.Windows, func(w WindowConfig) bool { return w.Name == mw.window.Name }) {
+			continue
+		}
+		ws.Windows = append(ws.Windows, mw.window)
+	}
+	return ws
+}
+
+// AddWorkspace appends ws to tmx.toml in the configuration directory and returns the
+// path of the file. The workspace is validated first and must not have the same name or
+// directory as a configured one.
+func AddWorkspace(ws WorkspaceConfig) (string, error) {
+	path, err := getPath()
+	if err != nil {
+		return "", err
+	}
+	return addWorkspace(path, ws)
+}
+
+// addWorkspace appends ws to tmx.toml in the directory path
+func addWorkspace(path string, ws WorkspaceConfig) (string, error) {
+	file := filepath.Join(path, mainConfigFile)
+
+	entry := formatWorkspace(ws)
+	var parsed Config
+	if _, err := toml.Decode(entry, &parsed); err != nil {
+		return file, fmt.Errorf("invalid workspace: %w", err)
+	}
+	if err := validateConfigFile(&parsed); err != nil {
+		return file, fmt.Errorf("invalid workspace: %w", err)
+	}
+
+	existing, _ := parseConfigFile(path)
+	added := parsed.Workspace[0]
+	for _, other := range existing.Workspace {
+		switch {
+		case other.Name == added.Name:
+			return file, fmt.Errorf("a workspace named %q already exists", added.Name)
+		case filepath.Clean(other.Directory) == filepath.Clean(added.Directory):
+			return file, fmt.Errorf("workspace %q already uses directory %s", other.Name, other.Directory)
+		}
+	}
+
+	// Separate the entry from the existing content by a blank line
+	content, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return file, err
+	}
+	switch {
+	case len(content) == 0:
+	case strings.HasSuffix(string(content), "\n\n"):
+	case strings.HasSuffix(string(content), "\n"):
+		entry = "\n" + entry
+	default:
+		entry = "\n\n" + entry
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(entry); err != nil {
+		return file, err
+	}
+	return file, f.Close()
+}
+
+// formatWorkspace formats the name, directory and windows of ws as a [[workspace]]
+// entry. Directories below the home directory are written with a leading ~.
+func formatWorkspace(ws WorkspaceConfig) string {
+	var sb strings.Builder
+	sb.WriteString("[[workspace]]\n")
+	fmt.Fprintf(&sb, "directory = %s\n", tomlString(contractHome(ws.Directory)))
+	fmt.Fprintf(&sb, "name = %s\n", tomlString(ws.Name))
+
+	if len(ws.Windows) > 0 {
+		sb.WriteString("windows = [\n")
+		for _, w := range ws.Windows {
+			fmt.Fprintf(&sb, "  {name = %s", tomlString(w.Name))
+			if w.Command != "" {
+				fmt.Fprintf(&sb, ", command = %s", tomlString(w.Command))
+			}
+			sb.WriteString("},\n")
+		}
+		sb.WriteString("]\n")
+	}
+	return sb.String()
+}
+
+// tomlString quotes s as a TOML basic string
+func tomlString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// contractHome replaces the home directory at the start of path with ~, the inverse of
+// expandHome
+func contractHome(path string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(homeDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") || filepath.IsAbs(rel) {
+		return path
+	}
+	if rel == "." {
+		return "~"
+	}
+	return "~/" + rel
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInitConfigDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tmx")
+
+	file, err := initConfigDir(dir, false)
+	if err != nil {
+		t.Fatalf("initConfigDir() error = %v", err)
+	}
+	if file != filepath.Join(dir, "tmx.toml") {
+		t.Errorf("initConfigDir() = %s, want tmx.toml in %s", file, dir)
+	}
+
+	// The starter config must load without errors or warnings
+	result := CheckDir(dir, "")
+	if len(result.Errors) > 0 || len(result.Warnings) > 0 {
+		t.Errorf("starter config has problems: %v %v", result.Errors, result.Warnings)
+	}
+
+	if _, err := initConfigDir(dir, false); !errors.Is(err, os.ErrExist) {
+		t.Errorf("initConfigDir() on an existing config: error = %v, want os.ErrExist", err)
+	}
+	if _, err := initConfigDir(dir, true); err != nil {
+		t.Errorf("initConfigDir() with force: error = %v", err)
+	}
+}
+
+func TestInferWorkspace(t *testing.T) {
+	t.Setenv("EDITOR", "nvim")
+	dir := writeConfigFiles(t, map[string]string{
+		"go.mod":     "module example\n",
+		"Cargo.toml": "",
+		"Makefile":   "",
+	})
+
+	ws := InferWorkspace(dir)
+	if ws.Name != filepath.Base(dir) || ws.Directory != dir {
+		t.Errorf("InferWorkspace() name = %s, directory = %s", ws.Name, ws.Directory)
+	}
+
+	// Cargo.toml would add a second test window, so it is skipped
+	expected := []WindowConfig{
+		{Name: "editor", Command: "nvim"},
+		{Name: "test", Command: "go test ./..."},
+		{Name: "build", Command: "make"},
+	}
+	if !reflect.DeepEqual(ws.Windows, expected) {
+		t.Errorf("InferWorkspace() windows = %v, want %v", ws.Windows, expected)
+	}
+
+	t.Setenv("EDITOR", "")
+	if ws := InferWorkspace(t.TempDir()); !reflect.DeepEqual(ws.Windows, []WindowConfig{{Name: "shell"}}) {
+		t.Errorf("InferWorkspace() without markers or editor = %v, want a single shell window", ws.Windows)
+	}
+}
+
+func TestAddWorkspace(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "search_depth = 2\n\n[[workspace]]\ndirectory = \"/src/api\"\nname = \"api\"",
+	})
+
+	ws := WorkspaceConfig{
+		Directory: "/src/web",
+		Name:      "web",
+		Windows: []WindowConfig{
+			{Name: "editor", Command: "nvim"},
+			{Name: "test", Command: `npm test -- --grep "login"`},
+			{Name: "shell"},
+		},
+	}
+	if _, err := addWorkspace(dir, ws); err != nil {
+		t.Fatalf("addWorkspace() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "tmx.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `search_depth = 2
+
+[[workspace]]
+directory = "/src/api"
+name = "api"
+
+[[workspace]]
+directory = "/src/web"
+name = "web"
+windows = [
+  {name = "editor", command = "nvim"},
+  {name = "test", command = "npm test -- --grep \"login\""},
+  {name = "shell"},
+]
+`
+	if string(content) != expected {
+		t.Errorf("tmx.toml =\n%s\nwant\n%s", content, expected)
+	}
+
+	cfg, errors := parseConfigFile(dir)
+	if len(errors) > 0 {
+		t.Fatalf("expected no errors, got: %v", errors)
+	}
+	if len(cfg.Workspace) != 2 || !reflect.DeepEqual(cfg.Workspace[1].Windows, ws.Windows) {
+		t.Errorf("added workspace was not read back: %v", cfg.Workspace)
+	}
+}
+
+func TestAddWorkspaceErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "[[workspace]]\ndirectory = \"/src/api\"\nname = \"api\"\n",
+	})
+
+	tests := []struct {
+		name string
+		ws   WorkspaceConfig
+		err  string
+	}{
+		{"same name", WorkspaceConfig{Directory: "/src/other", Name: "api"}, `a workspace named "api" already exists`},
+		{"same directory", WorkspaceConfig{Directory: "/src/api/", Name: "backend"}, `workspace "api" already uses directory /src/api`},
+		{"empty name", WorkspaceConfig{Directory: "/src/web"}, "invalid workspace"},
+		{"unknown variable", WorkspaceConfig{Directory: "/src/web", Name: "web", Windows: []WindowConfig{{Name: "run", Command: "{{.Port}}"}}}, "invalid workspace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := addWorkspace(dir, tt.ws)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("addWorkspace() error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+
+	// Nothing is written when the workspace is rejected
+	content, _ := os.ReadFile(filepath.Join(dir, "tmx.toml"))
+	if strings.Count(string(content), "[[workspace]]") != 1 {
+		t.Errorf("tmx.toml was modified:\n%s", content)
+	}
+}
+
+func TestContractHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := map[string]string{
+		home:                           "~",
+		filepath.Join(home, "Git/api"): "~/Git/api",
+		"/src/api":                     "/src/api",
+		home + "-other/api":            home + "-other/api",
+	}
+	for path, expected := range tests {
+		if got := contractHome(path); got != expected {
+			t.Errorf("contractHome(%s) = %s, want %s", path, got, expected)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package config
+
+import _ "embed"
+
+// Schema is the JSON Schema of config files, usable for editor validation of TOML, YAML
+// and JSON files alike
+//
+//go:embed tmx.schema.json
+var Schema []byte
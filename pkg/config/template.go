@@ -0,0 +1,167 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// TemplateConfig is a named set of windows, hooks and environment variables that
+// workspaces (and other templates) inherit with `extends`
+type TemplateConfig struct {
+	Name    string            `toml:"name"`
+	Extends string            `toml:"extends"`
+	Windows []WindowConfig    `toml:"windows"`
+	Hooks   HooksConfig       `toml:"hooks"`
+	Env     map[string]string `toml:"env"`
+	EnvFile string            `toml:"env_file"`
+}
+
+// validateTemplateConfigs validates the templates defined in a single file
+func validateTemplateConfigs(templates []TemplateConfig) error {
+	for i, tpl := range templates {
+		if err := validateTemplateConfig(i, tpl); err != nil {
+			return &tableError{table: "template", index: i, err: err}
+		}
+	}
+	return nil
+}
+
+// validateTemplateConfig validates the index-th template of a file
+func validateTemplateConfig(index int, tpl TemplateConfig) error {
+	if tpl.Name == "" {
+		return fmt.Errorf("template at index %d has an empty name", index)
+	}
+	for j, w := range tpl.Windows {
+		if w.Name == "" {
+			return fmt.Errorf("window at index %d in template %q has an empty name", j, tpl.Name)
+		}
+	}
+	return validateFocus(tpl.Windows, "template", tpl.Name)
+}
+
+// resolveWorkspaces replaces the workspaces in config, read from the directory dir, with
+// their templates applied. origins and templateOrigins tell where each workspace and
+// template was defined. Workspaces that cannot be resolved are dropped and reported;
+// the origins of the remaining ones are returned.
+func resolveWorkspaces(dir string, config *Config, origins, templateOrigins []origin) ([]origin, []ConfigError) {
+	var errors []ConfigError
+
+	templates := make(map[string]TemplateConfig, len(config.Template))
+	for i, tpl := range config.Template {
+		if _, exists := templates[tpl.Name]; exists {
+			err := templateOrigins[i].wrap(fmt.Errorf("duplicate template name: %s", tpl.Name))
+			errors = append(errors, newConfigError(dir, templateOrigins[i].file, err))
+			continue
+		}
+		templates[tpl.Name] = tpl
+	}
+
+	resolved := make([]WorkspaceConfig, 0, len(config.Workspace))
+	resolvedOrigins := make([]origin, 0, len(config.Workspace))
+	for i, ws := range config.Workspace {
+		if ws.Extends != "" {
+			base, err := resolveTemplate(ws.Extends, templates, nil)
+			if err != nil {
+				err := origins[i].wrap(fmt.Errorf("workspace %q: %w", ws.Name, err))
+				errors = append(errors, newConfigError(dir, origins[i].file, err))
+				continue
+			}
+			ws = inherit(ws, base)
+		}
+		resolved = append(resolved, ws)
+		resolvedOrigins = append(resolvedOrigins, origins[i])
+	}
+
+	config.Workspace = resolved
+	return resolvedOrigins, errors
+}
+
+// resolveTemplate returns the template called name with everything it extends applied.
+// chain holds the templates currently being resolved, to detect cycles.
+func resolveTemplate(name string, templates map[string]TemplateConfig, chain []string) (TemplateConfig, error) {
+	if slices.Contains(chain, name) {
+		return TemplateConfig{}, fmt.Errorf("template inheritance cycle: %s -> %s", strings.Join(chain, " -> "), name)
+	}
+
+	tpl, ok := templates[name]
+	if !ok {
+		return TemplateConfig{}, fmt.Errorf("unknown template %q", name)
+	}
+	if tpl.Extends == "" {
+		return tpl, nil
+	}
+
+	parent, err := resolveTemplate(tpl.Extends, templates, append(chain, name))
+	if err != nil {
+		return TemplateConfig{}, err
+	}
+
+	return TemplateConfig{
+		Name:    tpl.Name,
+		Windows: mergeWindows(parent.Windows, tpl.Windows),
+		Hooks:   mergeHooks(parent.Hooks, tpl.Hooks),
+		Env:     mergeEnv(parent.Env, tpl.Env),
+		EnvFile: cmp.Or(tpl.EnvFile, parent.EnvFile),
+	}, nil
+}
+
+// inherit applies the resolved template base to ws
+func inherit(ws WorkspaceConfig, base TemplateConfig) WorkspaceConfig {
+	ws.Windows = mergeWindows(base.Windows, ws.Windows)
+	ws.Hooks = mergeHooks(base.Hooks, ws.Hooks)
+	ws.Env = mergeEnv(base.Env, ws.Env)
+	ws.EnvFile = cmp.Or(ws.EnvFile, base.EnvFile)
+	return ws
+}
+
+// mergeWindows overlays windows onto base: a window with the name of an inherited one
+// overrides its settings in place, any other window is appended. Focusing a window
+// takes the focus away from the inherited windows.
+func mergeWindows(base, windows []WindowConfig) []WindowConfig {
+	merged := slices.Clone(base)
+	if slices.ContainsFunc(windows, func(w WindowConfig) bool { return w.Focus }) {
+		for i := range merged {
+			merged[i].Focus = false
+		}
+	}
+
+	for _, w := range windows {
+		i := slices.IndexFunc(merged, func(b WindowConfig) bool { return b.Name == w.Name })
+		if i < 0 {
+			merged = append(merged, w)
+			continue
+		}
+		if w.Command != "" {
+			merged[i].Command = w.Command
+		}
+		if w.Dir != "" {
+			merged[i].Dir = w.Dir
+		}
+		merged[i].Focus = merged[i].Focus || w.Focus
+		merged[i].Env = mergeEnv(merged[i].Env, w.Env)
+	}
+	return merged
+}
+
+// mergeHooks runs inherited hooks before the ones added by the extending config
+func mergeHooks(base, hooks HooksConfig) HooksConfig {
+	return HooksConfig{
+		OnCreate: append(slices.Clone(base.OnCreate), hooks.OnCreate...),
+	}
+}
+
+// mergeEnv combines two environments, env taking precedence over base
+func mergeEnv(base, env map[string]string) map[string]string {
+	if len(base) == 0 && len(env) == 0 {
+		return nil
+	}
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string, len(env))
+	}
+	maps.Copy(merged, env)
+	return merged
+}
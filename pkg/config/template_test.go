@@ -0,0 +1,227 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigTemplates(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"templates.toml": `
+[[template]]
+name = "base"
+windows = [{name = "editor", command = "nvim"}, {name = "shell", env = {PAGER = "less", TERM = "xterm"}}]
+hooks = {on_create = ["git fetch"]}
+env = {EDITOR = "nvim", LOG_LEVEL = "info"}
+env_file = ".env"
+
+[[template]]
+name = "go-service"
+extends = "base"
+windows = [{name = "tests", command = "go test ./..."}, {name = "logs"}]
+env = {GOFLAGS = "-mod=mod"}
+`,
+		"services.toml": `
+[[workspace]]
+directory = "/src/billing"
+name = "billing"
+extends = "go-service"
+windows = [{name = "editor", command = "nvim ."}, {name = "shell", env = {PAGER = "cat"}}, {name = "db", command = "psql"}]
+hooks = {on_create = ["make deps"]}
+env = {LOG_LEVEL = "debug"}
+
+[[workspace]]
+directory = "/src/plain"
+name = "plain"
+windows = [{name = "a"}]
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, errors := parseConfigFile(tmpDir)
+	if len(errors) > 0 {
+		t.Fatalf("expected no errors, got: %v", errors)
+	}
+	if len(cfg.Workspace) != 2 {
+		t.Fatalf("expected 2 workspaces, got %d", len(cfg.Workspace))
+	}
+
+	ws := cfg.Workspace[0]
+	expectedWindows := []WindowConfig{
+		{Name: "editor", Command: "nvim ."},
+		{Name: "shell", Env: map[string]string{"PAGER": "cat", "TERM": "xterm"}},
+		{Name: "tests", Command: "go test ./..."},
+		{Name: "logs"},
+		{Name: "db", Command: "psql"},
+	}
+	if !reflect.DeepEqual(ws.Windows, expectedWindows) {
+		t.Errorf("Windows = %+v, want %+v", ws.Windows, expectedWindows)
+	}
+
+	expectedHooks := []string{"git fetch", "make deps"}
+	if !reflect.DeepEqual(ws.Hooks.OnCreate, expectedHooks) {
+		t.Errorf("Hooks.OnCreate = %v, want %v", ws.Hooks.OnCreate, expectedHooks)
+	}
+
+	expectedEnv := map[string]string{"EDITOR": "nvim", "LOG_LEVEL": "debug", "GOFLAGS": "-mod=mod"}
+	if !reflect.DeepEqual(ws.Env, expectedEnv) {
+		t.Errorf("Env = %v, want %v", ws.Env, expectedEnv)
+	}
+
+	if ws.EnvFile != ".env" {
+		t.Errorf("EnvFile = %q, want it inherited from the template", ws.EnvFile)
+	}
+
+	if len(cfg.Workspace[1].Windows) != 1 || cfg.Workspace[1].Env != nil {
+		t.Errorf("expected the workspace without extends to be untouched, got %+v", cfg.Workspace[1])
+	}
+}
+
+func TestParseConfigTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		toml     string
+		contains string
+	}{
+		{
+			name: "UnknownTemplate",
+			toml: `
+[[workspace]]
+directory = "/src/a"
+name = "a"
+extends = "missing"
+`,
+			contains: `unknown template "missing"`,
+		},
+		{
+			name: "Cycle",
+			toml: `
+[[template]]
+name = "one"
+extends = "two"
+
+[[template]]
+name = "two"
+extends = "one"
+
+[[workspace]]
+directory = "/src/a"
+name = "a"
+extends = "one"
+`,
+			contains: "template inheritance cycle: one -> two -> one",
+		},
+		{
+			name: "EmptyTemplateName",
+			toml: `
+[[template]]
+windows = [{name = "a"}]
+`,
+			contains: "template at index 0 has an empty name",
+		},
+		{
+			name: "EmptyTemplateWindowName",
+			toml: `
+[[template]]
+name = "base"
+windows = [{command = "nvim"}]
+`,
+			contains: `window at index 0 in template "base" has an empty name`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tt.toml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, errors := parseConfigFile(tmpDir)
+			if len(errors) != 1 {
+				t.Fatalf("expected 1 error, got: %v", errors)
+			}
+			if errors[0].File != "tmx.toml" || !strings.Contains(errors[0].Error.Error(), tt.contains) {
+				t.Errorf("expected error in tmx.toml containing %q, got %s: %v", tt.contains, errors[0].File, errors[0].Error)
+			}
+			if len(cfg.Workspace) != 0 {
+				t.Errorf("expected unresolved workspaces to be dropped, got %+v", cfg.Workspace)
+			}
+		})
+	}
+
+	t.Run("DuplicateAcrossFiles", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		for _, name := range []string{"a.toml", "b.toml"} {
+			if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("[[template]]\nname = \"base\"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, errors := parseConfigFile(tmpDir)
+		if len(errors) != 1 || errors[0].File != "b.toml" {
+			t.Fatalf("expected a duplicate template error for b.toml, got: %v", errors)
+		}
+	})
+}
+
+func TestParseConfigWindowDirAndFocus(t *testing.T) {
+	t.Run("InheritedFocusMoves", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tomlData := `
+[[template]]
+name = "web"
+windows = [{name = "editor", focus = true}, {name = "frontend", dir = "web"}]
+
+[[workspace]]
+directory = "/src/shop"
+name = "shop"
+extends = "web"
+windows = [{name = "frontend", dir = "~/shop-web"}, {name = "logs", focus = true}]
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tomlData), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, errors := parseConfigFile(tmpDir)
+		if len(errors) > 0 {
+			t.Fatalf("expected no errors, got: %v", errors)
+		}
+
+		homeDir, _ := os.UserHomeDir()
+		expected := []WindowConfig{
+			{Name: "editor"},
+			{Name: "frontend", Dir: filepath.Join(homeDir, "shop-web")},
+			{Name: "logs", Focus: true},
+		}
+		if !reflect.DeepEqual(cfg.Workspace[0].Windows, expected) {
+			t.Errorf("Windows = %+v, want %+v", cfg.Workspace[0].Windows, expected)
+		}
+	})
+
+	t.Run("MultipleFocus", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tomlData := `
+[[workspace]]
+directory = "/src/shop"
+name = "shop"
+windows = [{name = "a", focus = true}, {name = "b", focus = true}]
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "tmx.toml"), []byte(tomlData), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, errors := parseConfigFile(tmpDir)
+		if len(errors) != 1 || !strings.Contains(errors[0].Error.Error(), `windows "a" and "b" in workspace "shop" both set focus`) {
+			t.Errorf("expected a focus conflict error, got: %v", errors)
+		}
+	})
+}
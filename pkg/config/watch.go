@@ -0,0 +1,194 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is how long the Watcher waits for further changes before reloading, so
+// that editors writing a file in several steps cause a single reload
+const reloadDelay = 100 * time.Millisecond
+
+// Reload describes the outcome of reloading the configuration after a change
+type Reload struct {
+	Config  *Config       // The configuration in use after the reload
+	Errors  []ConfigError // Problems found in the changed files
+	Applied bool          // False when the changed files had errors and the last good configuration was kept
+}
+
+// Watcher keeps the configuration up to date with the files in the config directory,
+// including the files they include, and reloads it whenever one of them changes. A
+// reload that finds errors keeps the last good configuration.
+type Watcher struct {
+	path    string
+	profile string
+	watcher *fsnotify.Watcher
+	reloads chan Reload
+	done    chan struct{}
+
+	mu      sync.RWMutex
+	config  *Config
+	errors  []ConfigError
+	watched []string // Directories currently watched
+}
+
+// NewWatcher loads the configuration with the given profile applied, like
+// ParseConfigProfile, and starts watching it for changes
+func NewWatcher(profile string) (*Watcher, error) {
+	path, err := getPath()
+	if err != nil {
+		return nil, err
+	}
+	return newWatcher(path, profile)
+}
+
+// newWatcher loads and watches the configuration in the directory path
+func newWatcher(path, profile string) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		path:    path,
+		profile: profile,
+		watcher: fsw,
+		reloads: make(chan Reload, 1),
+		done:    make(chan struct{}),
+	}
+
+	// The initial configuration is used even with errors, as ParseConfig does
+	l := loadConfig(path, profile)
+	applyDefaults(l.config)
+	w.config, w.errors = l.config, l.errors
+	w.watch(l)
+
+	go w.run()
+	return w, nil
+}
+
+// Config returns the configuration currently in use
+func (w *Watcher) Config() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.config
+}
+
+// Errors returns the problems found by the last load of the configuration
+func (w *Watcher) Errors() []ConfigError {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.errors
+}
+
+// Reloads delivers the outcome of reloads. A reload not yet received is replaced by the
+// next one, so slow receivers only miss intermediate states and always get the latest.
+func (w *Watcher) Reloads() <-chan Reload {
+	return w.reloads
+}
+
+// Close stops watching the configuration
+func (w *Watcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+// run reloads the configuration once changes to config files have settled
+func (w *Watcher) run() {
+	defer close(w.done)
+	defer close(w.reloads)
+
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.affects(event) {
+				timer.Reset(reloadDelay)
+			}
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+		case <-timer.C:
+			w.reload()
+		}
+	}
+}
+
+// affects reports whether event may change the configuration: a config file was
+// written, created, removed or renamed, or conf.d was created or removed
+func (w *Watcher) affects(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return false
+	}
+	return fileFormat(event.Name) != "" || event.Name == filepath.Join(w.path, dropInDir)
+}
+
+// reload loads the configuration again, keeping the last good one when it has errors
+func (w *Watcher) reload() {
+	l := loadConfig(w.path, w.profile)
+	applyDefaults(l.config)
+
+	w.mu.Lock()
+	applied := len(l.errors) == 0
+	if applied {
+		w.config = l.config
+	}
+	w.errors = l.errors
+	reload := Reload{Config: w.config, Errors: l.errors, Applied: applied}
+	w.mu.Unlock()
+
+	// Files may have been included or dropped, and conf.d created
+	w.watch(l)
+
+	// Replace a reload still pending so the latest one is never lost
+	for {
+		select {
+		case w.reloads <- reload:
+			return
+		default:
+		}
+		select {
+		case <-w.reloads:
+		default:
+		}
+	}
+}
+
+// watch watches the config directory, conf.d and the directories of all files loaded
+// by l, and stops watching directories no longer needed
+func (w *Watcher) watch(l *loader) {
+	dirs := []string{w.path, filepath.Join(w.path, dropInDir)}
+	for _, name := range l.files {
+		dirs = append(dirs, filepath.Dir(configPath(w.path, name)))
+	}
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var watched []string
+	for _, dir := range dirs {
+		// Directories that do not exist yet, like conf.d, are picked up on a later reload
+		if err := w.watcher.Add(dir); err == nil {
+			watched = append(watched, dir)
+		}
+	}
+	for _, dir := range w.watched {
+		if !slices.Contains(watched, dir) {
+			w.watcher.Remove(dir) //nolint:errcheck
+		}
+	}
+	w.watched = watched
+}
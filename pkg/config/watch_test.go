@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForReload returns the next reload of w, failing the test after a timeout
+func waitForReload(t *testing.T, w *Watcher) Reload {
+	t.Helper()
+	select {
+	case reload := <-w.Reloads():
+		return reload
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a reload")
+		return Reload{}
+	}
+}
+
+func TestWatcherReloads(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "search_depth = 2\n",
+	})
+
+	w, err := newWatcher(dir, "")
+	if err != nil {
+		t.Fatalf("newWatcher() error = %v", err)
+	}
+	defer w.Close()
+
+	if depth := w.Config().SearchDepth; depth != 2 {
+		t.Fatalf("SearchDepth = %d, want 2", depth)
+	}
+
+	// A valid change is applied
+	if err := os.WriteFile(filepath.Join(dir, "tmx.toml"), []byte("search_depth = 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reload := waitForReload(t, w)
+	if !reload.Applied || reload.Config.SearchDepth != 3 || w.Config().SearchDepth != 3 {
+		t.Errorf("after a valid change: applied = %v, SearchDepth = %d", reload.Applied, w.Config().SearchDepth)
+	}
+
+	// An invalid change is reported and the last good configuration kept
+	if err := os.WriteFile(filepath.Join(dir, "tmx.toml"), []byte("search_depth = \"deep\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reload = waitForReload(t, w)
+	if reload.Applied || len(reload.Errors) != 1 || len(w.Errors()) != 1 {
+		t.Errorf("after an invalid change: applied = %v, errors = %v", reload.Applied, reload.Errors)
+	}
+	if w.Config().SearchDepth != 3 || w.Config().GetMaxRecent() != 10 {
+		t.Errorf("last good configuration was not kept: SearchDepth = %d", w.Config().SearchDepth)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "tmx.toml"), []byte("search_depth = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForReload(t, w)
+
+	// Files in a conf.d created after the watcher started are picked up
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	waitForReload(t, w)
+	if err := os.WriteFile(filepath.Join(dir, "conf.d", "host.yaml"), []byte("max_recent: 4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reload = waitForReload(t, w)
+	if !reload.Applied || reload.Config.GetMaxRecent() != 4 {
+		t.Errorf("conf.d file not applied: applied = %v, MaxRecent = %d", reload.Applied, reload.Config.GetMaxRecent())
+	}
+}
+
+func TestWatcherIncludedFiles(t *testing.T) {
+	shared := t.TempDir()
+	included := filepath.Join(shared, "work.toml")
+	if err := os.WriteFile(included, []byte("search_depth = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "include = [\"" + included + "\"]\n",
+	})
+
+	w, err := newWatcher(dir, "")
+	if err != nil {
+		t.Fatalf("newWatcher() error = %v", err)
+	}
+	defer w.Close()
+
+	if err := os.WriteFile(included, []byte("search_depth = 5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if reload := waitForReload(t, w); reload.Config.SearchDepth != 5 {
+		t.Errorf("SearchDepth = %d, want 5", reload.Config.SearchDepth)
+	}
+}
+
+func TestWatcherKeepsLatestReload(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"tmx.toml": "search_depth = 2\n",
+	})
+
+	w, err := newWatcher(dir, "")
+	if err != nil {
+		t.Fatalf("newWatcher() error = %v", err)
+	}
+	defer w.Close()
+
+	// Two reloads settle before the first one is received
+	for _, depth := range []string{"3", "4"} {
+		if err := os.WriteFile(filepath.Join(dir, "tmx.toml"), []byte("search_depth = "+depth+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		w.reload()
+	}
+
+	reload := waitForReload(t, w)
+	if reload.Config.SearchDepth != 4 {
+		t.Errorf("SearchDepth = %d, want the latest reload with 4", reload.Config.SearchDepth)
+	}
+}
@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// ErrUnavailable is returned when no daemon answers on the socket, so callers can fall
+// back to doing the work themselves
+var ErrUnavailable = errors.New("daemon not available")
+
+// dialTimeout bounds how long connecting to the daemon may take before the CLI gives up
+// and works without it
+const dialTimeout = 200 * time.Millisecond
+
+// Client calls the methods of a running daemon
+type Client struct {
+	socket string
+}
+
+// Connect returns a client for the daemon listening on the default socket. No request is
+// made until a method is called, which fails with ErrUnavailable when no daemon is running.
+func Connect() (*Client, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return ConnectTo(path), nil
+}
+
+// ConnectTo returns a client for the daemon listening on the socket at path
+func ConnectTo(path string) *Client {
+	return &Client{socket: path}
+}
+
+// Status returns the pid and profile of the daemon
+func (c *Client) Status() (StatusResult, error) {
+	var status StatusResult
+	err := c.call(MethodStatus, nil, &status)
+	return status, err
+}
+
+// List returns the active tmux sessions, one `tmux list-sessions` line each
+func (c *Client) List() ([]string, error) {
+	var sessions []string
+	err := c.call(MethodList, nil, &sessions)
+	return sessions, err
+}
+
+// Kill kills the named tmux session
+func (c *Client) Kill(sessionName string) error {
+	return c.call(MethodKill, KillParams{Session: sessionName}, nil)
+}
+
+// Search returns the directory list shown in the picker for the given arguments
+func (c *Client) Search(path string, depth int, mode string) ([]byte, error) {
+	if path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		path = absPath
+	}
+	var list string
+	err := c.call(MethodSearch, SearchParams{Path: path, Depth: depth, Mode: mode}, &list)
+	return []byte(list), err
+}
+
+// Shutdown stops the daemon
+func (c *Client) Shutdown() error {
+	return c.call(MethodShutdown, nil, nil)
+}
+
+// call sends a request for method with params and decodes its result into result.
+// Failing to reach the daemon, or losing the connection, is reported as ErrUnavailable;
+// errors returned by the method itself are passed on as a MethodError.
+func (c *Client) call(method string, params, result any) error {
+	req := Request{Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+
+	conn, err := net.DialTimeout("unix", c.socket, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if resp.Error != "" {
+		return &MethodError{Message: resp.Error, Code: resp.Code}
+	}
+	if result != nil && len(resp.Result) > 0 {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package daemon implements the resident tmx daemon, which keeps the configuration,
+// directory search results and session state in memory, and the client the CLI uses to
+// talk to it. Requests and responses are JSON objects exchanged over a Unix socket, one
+// request per connection. Sessions are created by the CLI itself, so that their output,
+// hooks and environment belong to the invoking shell.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/vbrdnk/tmx/pkg/session"
+)
+
+// Methods served by the daemon
+const (
+	MethodStatus   = "status"   // Report the daemon's pid and profile
+	MethodList     = "list"     // List the active tmux sessions
+	MethodKill     = "kill"     // Kill a tmux session
+	MethodSearch   = "search"   // Return the directory list shown in the picker
+	MethodShutdown = "shutdown" // Stop the daemon
+)
+
+// socketEnv overrides the location of the daemon socket
+const socketEnv = "TMX_DAEMON_SOCKET"
+
+// Request is a call to one of the daemon's methods
+type Request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is the outcome of a Request: a result, or an error message
+type Response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Code   string          `json:"code,omitempty"` // Identifies the session error behind Error, if any
+}
+
+// errorCodes identify the session errors that callers match with errors.Is, so that they
+// survive the trip over the socket
+var errorCodes = []struct {
+	code string
+	err  error
+}{
+	{"session_not_found", session.ErrSessionNotFound},
+	{"no_server", session.ErrNoServer},
+	{"duplicate_session", session.ErrDuplicateSession},
+	{"nested_session", session.ErrNestedSession},
+}
+
+// errorCode returns the code of the session error err matches, or "" when there is none
+func errorCode(err error) string {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return ""
+}
+
+// MethodError is an error returned by a daemon method. It matches the session error it
+// stands for with errors.Is, as the error in the daemon did.
+type MethodError struct {
+	Message string
+	Code    string
+}
+
+func (e *MethodError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the session error identified by the code of e, if any
+func (e *MethodError) Unwrap() error {
+	for _, c := range errorCodes {
+		if c.code == e.Code {
+			return c.err
+		}
+	}
+	return nil
+}
+
+// StatusResult describes a running daemon
+type StatusResult struct {
+	Pid     int            `json:"pid"`
+	Profile string         `json:"profile,omitempty"`
+	Server  session.Server `json:"server"` // tmux server the daemon manages sessions on
+}
+
+// KillParams selects the session to kill
+type KillParams struct {
+	Session string `json:"session"`
+}
+
+// SearchParams mirror the arguments of a picker invocation
+type SearchParams struct {
+	Path  string `json:"path,omitempty"`  // Empty to search the configured search paths
+	Depth int    `json:"depth,omitempty"` // 0 to use the configured depth
+	Mode  string `json:"mode,omitempty"`  // Empty to use the configured search mode
+}
+
+// SocketPath returns the path of the daemon socket: $TMX_DAEMON_SOCKET, or tmx.sock in
+// $XDG_RUNTIME_DIR, or in a private directory below the temporary directory
+func SocketPath() (string, error) {
+	if path := os.Getenv(socketEnv); path != "" {
+		return path, nil
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "tmx.sock"), nil
+	}
+
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("tmx-%d", os.Getuid()))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	if err := checkPrivateDir(dir); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tmx.sock"), nil
+}
+
+// checkPrivateDir makes sure that dir is a directory owned by the current user with mode
+// 0700. The temporary directory is shared, so another user could have created dir first
+// to intercept the socket.
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Getuid() || info.Mode().Perm() != 0o700 {
+		return fmt.Errorf("refusing to use %s for the daemon socket: it must be a directory owned by you with mode 0700", dir)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv(socketEnv, "")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", t.TempDir())
+
+	path, err := SocketPath()
+	if err != nil {
+		t.Fatalf("SocketPath() error: %v", err)
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("socket directory mode = %o, want 700", perm)
+	}
+
+	// A directory left open to others is refused rather than trusted
+	if err := os.Chmod(filepath.Dir(path), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SocketPath(); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("SocketPath() with a world-writable directory error = %v, want a refusal", err)
+	}
+}
+
+func TestCheckPrivateDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "private")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivateDir(dir); err != nil {
+		t.Errorf("checkPrivateDir() on a private directory error: %v", err)
+	}
+
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivateDir(link); err == nil {
+		t.Error("checkPrivateDir() accepted a symlink")
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivateDir(file); err == nil {
+		t.Error("checkPrivateDir() accepted a file")
+	}
+}
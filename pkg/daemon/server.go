@@ -0,0 +1,242 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/discovery"
+	"github.com/vbrdnk/tmx/pkg/session"
+)
+
+// ConfigSource provides the configuration served by the daemon and tells about changes
+// to it; config.Watcher is the usual implementation
+type ConfigSource interface {
+	Config() *config.Config
+	Reloads() <-chan config.Reload
+}
+
+// Server answers requests on the daemon socket. It keeps the configuration, a session
+// manager built from it and the results of previous searches in memory, and rebuilds
+// them when the configuration is reloaded.
+type Server struct {
+	profile  string
+	tmux     session.Server
+	listener net.Listener
+	closing  sync.Once
+
+	mu       sync.RWMutex
+	config   *config.Config
+	sessions *session.SessionManager
+	searches map[SearchParams]*searchEntry
+}
+
+// searchEntry holds the last directory list built for a search. It is served right away
+// and refreshed in the background, so repeated searches stay fast and become current.
+type searchEntry struct {
+	mu         sync.Mutex
+	list       []byte
+	refreshing bool
+}
+
+// NewServer creates a Server listening on the socket at path, serving the configuration
+// of source and managing sessions on the tmux server tmux. A stale socket left by a daemon that did not shut down cleanly is replaced;
+// a socket with a live daemon behind it is an error.
+func NewServer(path string, source ConfigSource, profile string, tmux session.Server) (*Server, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already running on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	s := &Server{profile: profile, tmux: tmux, listener: listener}
+	s.apply(source.Config())
+
+	go func() {
+		for reload := range source.Reloads() {
+			for _, e := range reload.Errors {
+				log.Printf("Configuration error: %s", e)
+			}
+			if reload.Applied {
+				log.Printf("Configuration reloaded")
+				s.apply(reload.Config)
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+// apply switches to cfg, dropping the state derived from the previous configuration
+func (s *Server) apply(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = cfg
+	s.sessions = session.NewSessionManager(cfg)
+	s.sessions.SetServer(s.tmux)
+	s.searches = make(map[SearchParams]*searchEntry)
+}
+
+// Serve accepts connections until the server is closed or shut down by a client
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops the server and removes its socket
+func (s *Server) Close() error {
+	var err error
+	s.closing.Do(func() { err = s.listener.Close() })
+	return err
+}
+
+// handle answers the single request sent on conn
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	result, err := s.dispatch(req)
+	var resp Response
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Code = errorCode(err)
+	}
+	json.NewEncoder(conn).Encode(resp) //nolint:errcheck
+
+	if req.Method == MethodShutdown {
+		s.Close() //nolint:errcheck
+	}
+}
+
+// dispatch runs the method named in req
+func (s *Server) dispatch(req Request) (any, error) {
+	s.mu.RLock()
+	sessions := s.sessions
+	s.mu.RUnlock()
+
+	switch req.Method {
+	case MethodStatus:
+		return StatusResult{Pid: os.Getpid(), Profile: s.profile, Server: s.tmux}, nil
+
+	case MethodList:
+		list, err := sessions.Sessions()
+		if errors.Is(err, session.ErrNoServer) {
+			return []string{}, nil
+		}
+		return list, err
+
+	case MethodKill:
+		var params KillParams
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, sessions.KillSession(params.Session)
+
+	case MethodSearch:
+		var params SearchParams
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		list, err := s.search(params)
+		return string(list), err
+
+	case MethodShutdown:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown method %q", req.Method)
+}
+
+// decodeParams decodes the parameters of req into params
+func decodeParams(req Request, params any) error {
+	if len(req.Params) == 0 {
+		return fmt.Errorf("missing parameters for %s", req.Method)
+	}
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return fmt.Errorf("invalid parameters for %s: %w", req.Method, err)
+	}
+	return nil
+}
+
+// search returns the directory list for params, serving the previous result for the
+// same parameters when there is one and refreshing it in the background
+func (s *Server) search(params SearchParams) ([]byte, error) {
+	s.mu.Lock()
+	cfg := s.config
+	entry, ok := s.searches[params]
+	if !ok {
+		entry = &searchEntry{}
+		s.searches[params] = entry
+	}
+	s.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.list != nil {
+		if !entry.refreshing {
+			entry.refreshing = true
+			go entry.refresh(cfg, params)
+		}
+		return entry.list, nil
+	}
+
+	list, err := buildList(cfg, params)
+	if err != nil {
+		return nil, err
+	}
+	entry.list = list
+	return list, nil
+}
+
+// refresh rebuilds the list of entry, keeping the previous one when the search fails
+func (entry *searchEntry) refresh(cfg *config.Config, params SearchParams) {
+	list, err := buildList(cfg, params)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if err == nil {
+		entry.list = list
+	}
+	entry.refreshing = false
+}
+
+// buildList builds the directory list for params as the picker would show it
+func buildList(cfg *config.Config, params SearchParams) ([]byte, error) {
+	cfg, err := cfg.WithSearchMode(params.Mode)
+	if err != nil {
+		return nil, err
+	}
+	return discovery.NewDirectorySelector(cfg).BuildList(params.Path, params.Depth)
+}
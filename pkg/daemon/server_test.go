@@ -0,0 +1,240 @@
+package daemon
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/session"
+)
+
+// staticSource serves a fixed configuration and delivers the reloads sent to it
+type staticSource struct {
+	config  *config.Config
+	reloads chan config.Reload
+}
+
+func newStaticSource(cfg *config.Config) *staticSource {
+	return &staticSource{config: cfg, reloads: make(chan config.Reload, 1)}
+}
+
+func (s *staticSource) Config() *config.Config        { return s.config }
+func (s *staticSource) Reloads() <-chan config.Reload { return s.reloads }
+
+// startServer starts a server for source on a socket in a temporary directory and returns
+// a client connected to it
+func startServer(t *testing.T, source ConfigSource) *Client {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "tmx.sock")
+	server, err := NewServer(socket, source, "work", session.Server{Name: "work"})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	go server.Serve() //nolint:errcheck
+	t.Cleanup(func() { server.Close() })
+
+	return ConnectTo(socket)
+}
+
+// searchConfig returns a configuration searching dir without zoxide or the index cache
+func searchConfig(dir string) *config.Config {
+	useZoxide := false
+	return &config.Config{
+		UseZoxide:   &useZoxide,
+		SearchPaths: []config.SearchPathConfig{{Path: dir}},
+	}
+}
+
+func TestStatus(t *testing.T) {
+	client := startServer(t, newStaticSource(&config.Config{}))
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if status.Pid != os.Getpid() {
+		t.Errorf("Status().Pid = %d, want %d", status.Pid, os.Getpid())
+	}
+	if status.Profile != "work" {
+		t.Errorf("Status().Profile = %q, want %q", status.Profile, "work")
+	}
+	if want := (session.Server{Name: "work"}); status.Server != want {
+		t.Errorf("Status().Server = %+v, want %+v", status.Server, want)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "project1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	client := startServer(t, newStaticSource(searchConfig(dir)))
+
+	list, err := client.Search("", 1, "")
+	if err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	if !strings.Contains(string(list), filepath.Join(dir, "project1")) {
+		t.Errorf("Search() = %q, want it to contain project1", list)
+	}
+
+	// A directory created since is picked up once the background refresh has run
+	if err := os.Mkdir(filepath.Join(dir, "project2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(string(list), filepath.Join(dir, "project2")) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Search() = %q, want it to pick up project2", list)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if list, err = client.Search("", 1, ""); err != nil {
+			t.Fatalf("Search() error: %v", err)
+		}
+	}
+}
+
+func TestSearchInvalidMode(t *testing.T) {
+	client := startServer(t, newStaticSource(searchConfig(t.TempDir())))
+
+	_, err := client.Search("", 0, "bogus")
+	if err == nil {
+		t.Fatal("Search() with an invalid mode succeeded")
+	}
+	if errors.Is(err, ErrUnavailable) {
+		t.Errorf("Search() error = %v, want a method error", err)
+	}
+}
+
+func TestReloadReplacesConfig(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(second, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	source := newStaticSource(searchConfig(first))
+	client := startServer(t, source)
+
+	if _, err := client.Search("", 1, ""); err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	source.reloads <- config.Reload{Config: searchConfig(second), Applied: true}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		list, err := client.Search("", 1, "")
+		if err != nil {
+			t.Fatalf("Search() error: %v", err)
+		}
+		if strings.Contains(string(list), filepath.Join(second, "other")) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Search() = %q, want the reloaded search paths", list)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	client := startServer(t, newStaticSource(&config.Config{}))
+
+	err := client.call("bogus", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown method "bogus"`) {
+		t.Errorf("call(bogus) error = %v, want unknown method", err)
+	}
+}
+
+func TestSessionErrorsSurvive(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+	os.Unsetenv("TMUX")
+
+	// startServer manages sessions on the "work" tmux server, which is not running yet
+	client := startServer(t, newStaticSource(&config.Config{}))
+	tmux := session.Server{Name: "work"}
+
+	err := client.Kill("missing")
+	if !errors.Is(err, session.ErrNoServer) {
+		t.Errorf("Kill() without a tmux server error = %v, want ErrNoServer", err)
+	}
+
+	if err := tmux.Command("new-session", "-ds", "other").Execute(); err != nil {
+		t.Fatalf("new-session error: %v", err)
+	}
+	t.Cleanup(func() { tmux.Command("kill-server").Execute() }) //nolint:errcheck
+
+	err = client.Kill("missing")
+	if !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("Kill() of a missing session error = %v, want ErrSessionNotFound", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Kill() error = %v, want the message of the daemon", err)
+	}
+}
+
+func TestNewServerSocket(t *testing.T) {
+	t.Run("ReplacesStaleSocket", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "tmx.sock")
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Keep the socket file behind, as a crashed daemon would
+		listener.(*net.UnixListener).SetUnlinkOnClose(false)
+		listener.Close()
+
+		server, err := NewServer(socket, newStaticSource(&config.Config{}), "", session.Server{})
+		if err != nil {
+			t.Fatalf("NewServer() over a stale socket error: %v", err)
+		}
+		server.Close()
+	})
+
+	t.Run("RefusesLiveSocket", func(t *testing.T) {
+		client := startServer(t, newStaticSource(&config.Config{}))
+
+		if _, err := NewServer(client.socket, newStaticSource(&config.Config{}), "", session.Server{}); err == nil {
+			t.Error("NewServer() on the socket of a running daemon succeeded")
+		}
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "tmx.sock")
+	server, err := NewServer(socket, newStaticSource(&config.Config{}), "", session.Server{})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve() }()
+
+	client := ConnectTo(socket)
+	if err := client.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve() error after shutdown: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve() did not return after shutdown")
+	}
+
+	if _, err := client.Status(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Status() after shutdown error = %v, want ErrUnavailable", err)
+	}
+}
@@ -1,39 +1,106 @@
 package discovery
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/frecency"
+	"github.com/vbrdnk/tmx/pkg/index"
 	"github.com/vbrdnk/tmx/pkg/search"
 	"github.com/vbrdnk/tmx/pkg/ui"
 )
 
 // DirectorySelector orchestrates directory discovery and selection
 type DirectorySelector struct {
-	searcher *search.DirectorySearcher
-	config   *config.Config
+	config *config.Config
+	fd     bool // Whether fd is installed, looked up once for every search
+
+	frecency  frecency.Source // nil when frecency suggestions are disabled
+	cache     *index.Cache    // nil when the index cache is disabled
+	refreshes sync.WaitGroup
 }
 
 // NewDirectorySelector creates a new DirectorySelector instance
 func NewDirectorySelector(cfg *config.Config) *DirectorySelector {
-	return &DirectorySelector{
-		searcher: search.NewDirectorySearcher(),
-		config:   cfg,
+	ds := &DirectorySelector{
+		config: cfg,
+		fd:     search.FdAvailable(),
+	}
+
+	if cfg != nil && cfg.GetUseZoxide() {
+		if source, err := frecency.New(cfg.GetFrecencySource()); err == nil {
+			ds.frecency = source
+		}
+	}
+
+	if cfg != nil && cfg.GetIndexCache() {
+		// Without a usable cache directory, searches simply run uncached
+		if cache, err := index.NewCache(); err == nil {
+			ds.cache = cache
+		}
+	}
+
+	return ds
+}
+
+// searchOptions derives the directory search options from the config
+func searchOptions(cfg *config.Config) search.Options {
+	if cfg == nil {
+		return search.Options{}
+	}
+	return search.Options{
+		Excludes:       cfg.SearchExclude,
+		Includes:       cfg.SearchInclude,
+		ExcludeHidden:  !cfg.GetSearchHidden(),
+		RespectIgnore:  cfg.GetUseGitignore(),
+		ProjectsOnly:   cfg.GetSearchMode() == config.SearchModeProject,
+		ProjectMarkers: cfg.ProjectMarkers,
 	}
 }
 
-// SelectDirectory orchestrates the full directory selection workflow
+// frecencyPrefix marks directories suggested by the frecency source in the picker
+const frecencyPrefix = "★ "
+
+// searchRoot is a single directory searched by BuildList with its own settings
+type searchRoot struct {
+	path  string
+	depth int
+	label string
+	opts  search.Options
+}
+
+// SelectDirectory orchestrates the full directory selection workflow.
+// An empty basePath searches the configured search paths. Results are streamed into
+// the picker as they are found and the search stops once a selection is made.
 func (ds *DirectorySelector) SelectDirectory(basePath string, cliDepth int) (string, error) {
-	dirList, err := ds.BuildList(basePath, cliDepth)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lines, err := ds.StreamList(ctx, basePath, cliDepth)
 	if err != nil {
 		return "", fmt.Errorf("error building directory list: %v", err)
 	}
 
-	selectedDir, err := ui.FuzzyFind(dirList)
+	selectedDir, err := ui.FuzzyFindStream(lines)
+	cancel()
+	return ds.selection(selectedDir, err)
+}
+
+// SelectFromList lets the user pick a directory from a list built by BuildList, such as
+// one served by the tmx daemon
+func (ds *DirectorySelector) SelectFromList(list []byte) (string, error) {
+	return ds.selection(ui.FuzzyFind(list))
+}
+
+// selection returns the directory picked on line, exiting when nothing was picked
+func (ds *DirectorySelector) selection(line string, err error) (string, error) {
 	if err != nil {
 		if errors.Is(err, ui.ErrNoSelection) {
 			color.Yellow("No folder selected, exiting.")
@@ -42,45 +109,219 @@ func (ds *DirectorySelector) SelectDirectory(basePath string, cliDepth int) (str
 		return "", err
 	}
 
-	// Strip the frecency indicator if present
-	selectedDir = strings.TrimPrefix(selectedDir, "★ ")
-	return selectedDir, nil
+	return ds.parseSelection(line), nil
 }
 
-// BuildList constructs a list of directories combining zoxide frecency and find/fd results
-func (ds *DirectorySelector) BuildList(path string, cliDepth int) ([]byte, error) {
-	searchDepth := ds.config.GetSearchDepth(cliDepth)
-	useZoxide := ds.config.GetUseZoxide()
+// parseSelection strips the frecency indicator, score and search path label from a picker line
+func (ds *DirectorySelector) parseSelection(line string) string {
+	if rest, ok := strings.CutPrefix(line, frecencyPrefix); ok {
+		// Frecent directories are absolute, so anything before the path is a score
+		if _, dir, found := strings.Cut(rest, " "); found && !strings.HasPrefix(rest, "/") {
+			return dir
+		}
+		return rest
+	}
+	if ds.config == nil {
+		return line
+	}
+	for _, sp := range ds.config.SearchPaths {
+		if sp.Label != "" && strings.HasPrefix(line, labelPrefix(sp.Label)) {
+			return strings.TrimPrefix(line, labelPrefix(sp.Label))
+		}
+	}
+	return line
+}
 
-	var directories []string
-	seenPaths := make(map[string]bool)
+// labelPrefix returns the marker shown in front of directories found under a labelled search path
+func labelPrefix(label string) string {
+	return "[" + label + "] "
+}
+
+// linePrefix returns the marker shown in front of directories found under root
+func (root searchRoot) linePrefix() string {
+	if root.label == "" {
+		return ""
+	}
+	return labelPrefix(root.label)
+}
+
+// frecentLines returns the picker lines for the frecent directories below any of roots,
+// ignoring frecency source errors (not installed, no results, etc.)
+func (ds *DirectorySelector) frecentLines(roots []searchRoot) (lines, dirs []string) {
+	if ds.frecency == nil {
+		return nil, nil
+	}
+
+	entries, err := ds.frecency.List()
+	if err != nil {
+		return nil, nil
+	}
+
+	opts := frecency.QueryOptions{
+		Limit:    ds.config.GetFrecencyLimit(),
+		MinScore: ds.config.GetFrecencyMinScore(),
+	}
+	showScores := ds.config.GetFrecencyShowScores()
 
-	// 1. Get zoxide results if enabled
-	if useZoxide {
-		zoxideResults, err := ds.searcher.QueryZoxideCache(path)
-		if err == nil && len(zoxideResults) > 0 {
-			for _, dir := range zoxideResults {
-				if !seenPaths[dir] {
-					directories = append(directories, "★ "+dir)
-					seenPaths[dir] = true
-				}
+	for _, root := range roots {
+		for _, entry := range frecency.Under(entries, root.path, opts) {
+			line := frecencyPrefix + entry.Dir
+			if showScores {
+				line = fmt.Sprintf("%s%.1f %s", frecencyPrefix, entry.Score, entry.Dir)
 			}
+			lines = append(lines, line)
+			dirs = append(dirs, entry.Dir)
+		}
+	}
+	return lines, dirs
+}
+
+// searchRoots returns the directories to search. An explicit path wins; otherwise the
+// configured search paths are used, falling back to the home directory.
+func (ds *DirectorySelector) searchRoots(path string, cliDepth int) ([]searchRoot, error) {
+	opts := searchOptions(ds.config)
+
+	if path != "" {
+		return []searchRoot{{path: path, depth: ds.config.GetSearchDepth(cliDepth), opts: opts}}, nil
+	}
+
+	if len(ds.config.SearchPaths) == 0 {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user home directory: %v", err)
+		}
+		return []searchRoot{{path: homeDir, depth: ds.config.GetSearchDepth(cliDepth), opts: opts}}, nil
+	}
+
+	roots := make([]searchRoot, 0, len(ds.config.SearchPaths))
+	for _, sp := range ds.config.SearchPaths {
+		rootOpts := opts
+		rootOpts.Excludes = append(slices.Clone(opts.Excludes), sp.Exclude...)
+
+		// CLI depth overrides the per-path depth, which overrides the global one
+		depth := ds.config.GetSearchDepth(cliDepth)
+		if cliDepth == 0 && sp.Depth > 0 {
+			depth = sp.Depth
 		}
-		// Silently ignore zoxide errors (not installed, no results, etc.)
+
+		roots = append(roots, searchRoot{path: sp.Path, depth: depth, label: sp.Label, opts: rootOpts})
 	}
+	return roots, nil
+}
 
-	// 2. Get find/fd results
-	findResults, err := ds.searcher.Search(path, searchDepth)
+// BuildList constructs a list of directories combining frecency suggestions and directory search
+// results. An empty path searches all configured search paths in parallel.
+func (ds *DirectorySelector) BuildList(path string, cliDepth int) ([]byte, error) {
+	roots, err := ds.searchRoots(path, cliDepth)
 	if err != nil {
 		return nil, err
 	}
+	var directories []string
+	seenPaths := make(map[string]bool)
 
-	for _, dir := range findResults {
+	// 1. Get frecency suggestions (zoxide by default) if enabled
+	frecentLines, frecentDirs := ds.frecentLines(roots)
+	for i, dir := range frecentDirs {
 		if !seenPaths[dir] {
-			directories = append(directories, dir)
+			directories = append(directories, frecentLines[i])
 			seenPaths[dir] = true
 		}
 	}
 
+	// 2. Search every root in parallel
+	results := make([][]string, len(roots))
+	errs := make([]error, len(roots))
+	var wg sync.WaitGroup
+	for i, root := range roots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = ds.search(root)
+		}()
+	}
+	wg.Wait()
+
+	// 3. Merge in configured order; a failing root is skipped unless it is the only one
+	failed := 0
+	for i, root := range roots {
+		if errs[i] != nil {
+			failed++
+			if len(roots) > 1 {
+				color.Yellow("Skipping search path %s: %v", root.path, errs[i])
+			}
+			continue
+		}
+
+		for _, dir := range results[i] {
+			if !seenPaths[dir] {
+				directories = append(directories, root.linePrefix()+dir)
+				seenPaths[dir] = true
+			}
+		}
+	}
+	if failed == len(roots) {
+		return nil, errs[0]
+	}
+
 	return []byte(strings.Join(directories, "\n")), nil
 }
+
+// searcher returns the directory searcher for root
+func (ds *DirectorySelector) searcher(root searchRoot) *search.DirectorySearcher {
+	return search.NewDirectorySearcherWithFd(root.opts, ds.fd)
+}
+
+// search returns the directories below root. With the index cache enabled, cached
+// results are served immediately and refreshed in the background when stale.
+func (ds *DirectorySelector) search(root searchRoot) ([]string, error) {
+	if ds.cache == nil {
+		return ds.searcher(root).Search(root.path, root.depth)
+	}
+
+	if entry, ok := ds.cache.Load(root.path, root.depth, root.opts); ok {
+		ds.refreshes.Add(1)
+		go func() {
+			defer ds.refreshes.Done()
+			ds.cache.Refresh(root.path, root.depth, root.opts) //nolint:errcheck
+		}()
+		return entry.Dirs, nil
+	}
+
+	entry, err := ds.cache.Build(root.path, root.depth, root.opts)
+	if err != nil {
+		return nil, err
+	}
+	return entry.Dirs, nil
+}
+
+// WaitForRefresh blocks until background index refreshes have finished
+func (ds *DirectorySelector) WaitForRefresh() {
+	ds.refreshes.Wait()
+}
+
+// RebuildIndex rebuilds the index cache for path, or for every configured search path
+// when path is empty, and returns the new entries
+func (ds *DirectorySelector) RebuildIndex(path string, cliDepth int) ([]*index.Entry, error) {
+	cache := ds.cache
+	if cache == nil {
+		var err error
+		if cache, err = index.NewCache(); err != nil {
+			return nil, err
+		}
+	}
+
+	roots, err := ds.searchRoots(path, cliDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*index.Entry
+	for _, root := range roots {
+		entry, err := cache.Build(root.path, root.depth, root.opts)
+		if err != nil {
+			return entries, fmt.Errorf("failed to index %s: %w", root.path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/frecency"
 )
 
 func TestNewDirectorySelector(t *testing.T) {
@@ -16,9 +17,6 @@ func TestNewDirectorySelector(t *testing.T) {
 		if ds == nil {
 			t.Fatal("NewDirectorySelector() returned nil")
 		}
-		if ds.searcher == nil {
-			t.Error("Expected searcher to be initialized")
-		}
 	})
 
 	t.Run("WithConfig", func(t *testing.T) {
@@ -30,9 +28,6 @@ func TestNewDirectorySelector(t *testing.T) {
 		if ds.config != cfg {
 			t.Error("Expected config to match provided config")
 		}
-		if ds.searcher == nil {
-			t.Error("Expected searcher to be initialized")
-		}
 	})
 }
 
@@ -224,3 +219,140 @@ func TestBuildListConfigDefaults(t *testing.T) {
 		}
 	})
 }
+
+func TestBuildListSearchPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"git/api/src", "git/web", "work/tool/vendor", "work/other"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create test dir %s: %v", dir, err)
+		}
+	}
+
+	useZoxide := false
+	cfg := &config.Config{
+		UseZoxide: &useZoxide,
+		SearchPaths: []config.SearchPathConfig{
+			{Path: filepath.Join(tmpDir, "git"), Depth: 2},
+			{Path: filepath.Join(tmpDir, "work"), Label: "work", Exclude: []string{"vendor", "other"}},
+			{Path: filepath.Join(tmpDir, "missing")},
+		},
+	}
+	ds := NewDirectorySelector(cfg)
+
+	t.Run("MergesRootsInOrder", func(t *testing.T) {
+		result, err := ds.BuildList("", 0)
+		if err != nil {
+			t.Fatalf("BuildList() error = %v", err)
+		}
+
+		expected := []string{
+			filepath.Join(tmpDir, "git/api"),
+			filepath.Join(tmpDir, "git/api/src"),
+			filepath.Join(tmpDir, "git/web"),
+			"[work] " + filepath.Join(tmpDir, "work/tool"),
+		}
+		if got := strings.Split(string(result), "\n"); strings.Join(got, "|") != strings.Join(expected, "|") {
+			t.Errorf("BuildList() = %v, want %v", got, expected)
+		}
+	})
+
+	t.Run("CLIDepthOverridesPathDepth", func(t *testing.T) {
+		result, err := ds.BuildList("", 1)
+		if err != nil {
+			t.Fatalf("BuildList() error = %v", err)
+		}
+		if strings.Contains(string(result), "src") {
+			t.Error("Expected CLI depth 1 to override the per-path depth")
+		}
+	})
+
+	t.Run("ExplicitPathOverridesSearchPaths", func(t *testing.T) {
+		result, err := ds.BuildList(filepath.Join(tmpDir, "work"), 1)
+		if err != nil {
+			t.Fatalf("BuildList() error = %v", err)
+		}
+		// Per-path excludes and labels only apply to configured search paths
+		if !strings.Contains(string(result), "other") {
+			t.Errorf("Expected unlabelled, unfiltered results, got %q", result)
+		}
+		if strings.Contains(string(result), "[work]") {
+			t.Errorf("Expected no label for an explicit path, got %q", result)
+		}
+	})
+
+	t.Run("AllRootsFailing", func(t *testing.T) {
+		failing := NewDirectorySelector(&config.Config{
+			UseZoxide:   &useZoxide,
+			SearchPaths: []config.SearchPathConfig{{Path: filepath.Join(tmpDir, "missing")}},
+		})
+		if _, err := failing.BuildList("", 0); err == nil {
+			t.Error("Expected an error when no search path can be read")
+		}
+	})
+}
+
+func TestParseSelection(t *testing.T) {
+	cfg := &config.Config{
+		SearchPaths: []config.SearchPathConfig{{Path: "/git", Label: "git"}},
+	}
+	ds := NewDirectorySelector(cfg)
+
+	tests := map[string]string{
+		"/git/project":        "/git/project",
+		"★ /git/project":      "/git/project",
+		"★ 12.5 /git/project": "/git/project",
+		"★ 3.0 /git/my dir":   "/git/my dir",
+		"[git] /git/project":  "/git/project",
+		"[other] /x":          "[other] /x",
+	}
+	for input, expected := range tests {
+		if got := ds.parseSelection(input); got != expected {
+			t.Errorf("parseSelection(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+// fakeFrecency is a frecency source with fixed entries
+type fakeFrecency struct {
+	entries []frecency.Entry
+}
+
+func (f *fakeFrecency) Name() string                    { return "fake" }
+func (f *fakeFrecency) List() ([]frecency.Entry, error) { return f.entries, nil }
+func (f *fakeFrecency) Add(string) error                { return nil }
+
+func TestFrecentLines(t *testing.T) {
+	source := &fakeFrecency{entries: []frecency.Entry{
+		{Dir: "/git/a", Score: 40},
+		{Dir: "/git-old/b", Score: 30},
+		{Dir: "/git/c", Score: 2},
+		{Dir: "/git/d", Score: 1},
+	}}
+	roots := []searchRoot{{path: "/git"}}
+
+	t.Run("WithScores", func(t *testing.T) {
+		ds := &DirectorySelector{config: &config.Config{}, frecency: source}
+		lines, dirs := ds.frecentLines(roots)
+
+		expectedLines := []string{"★ 40.0 /git/a", "★ 2.0 /git/c", "★ 1.0 /git/d"}
+		if strings.Join(lines, "|") != strings.Join(expectedLines, "|") {
+			t.Errorf("frecentLines() lines = %v, want %v", lines, expectedLines)
+		}
+		if strings.Join(dirs, "|") != "/git/a|/git/c|/git/d" {
+			t.Errorf("frecentLines() dirs = %v", dirs)
+		}
+	})
+
+	t.Run("LimitMinScoreNoScores", func(t *testing.T) {
+		limit := 1
+		showScores := false
+		minScore := 1.5
+		cfg := &config.Config{FrecencyLimit: &limit, FrecencyMinScore: &minScore, FrecencyShowScores: &showScores}
+		ds := &DirectorySelector{config: cfg, frecency: source}
+
+		lines, _ := ds.frecentLines(roots)
+		if strings.Join(lines, "|") != "★ /git/a" {
+			t.Errorf("frecentLines() = %v, want [★ /git/a]", lines)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// StreamList is the streaming counterpart of BuildList. Frecency suggestions are sent
+// first, followed by search results as they are found, each directory at most once.
+// The channel is closed when every search has finished or ctx is cancelled.
+// Search paths that cannot be read are reported up front and skipped.
+func (ds *DirectorySelector) StreamList(ctx context.Context, path string, cliDepth int) (<-chan string, error) {
+	roots, err := ds.searchRoots(path, cliDepth)
+	if err != nil {
+		return nil, err
+	}
+	if roots, err = readableRoots(roots); err != nil {
+		return nil, err
+	}
+
+	lines := make(chan string, 256)
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+	)
+
+	// send emits the line for dir unless dir was emitted before; it reports false
+	// once the consumer has gone away
+	send := func(line, dir string) bool {
+		mu.Lock()
+		if seen[dir] {
+			mu.Unlock()
+			return true
+		}
+		seen[dir] = true
+		mu.Unlock()
+
+		select {
+		case lines <- line:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(lines)
+
+		// 1. Frecency suggestions are quick and go to the top of the list
+		frecentLines, frecentDirs := ds.frecentLines(roots)
+		for i, dir := range frecentDirs {
+			if !send(frecentLines[i], dir) {
+				return
+			}
+		}
+
+		// 2. Stream every root in parallel
+		var wg sync.WaitGroup
+		for _, root := range roots {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ds.streamRoot(ctx, root, func(dir string) { send(root.linePrefix()+dir, dir) })
+			}()
+		}
+		wg.Wait()
+	}()
+
+	return lines, nil
+}
+
+// streamRoot passes the directories below root to emit. With the index cache enabled,
+// cached results are emitted at once and refreshed in the background; a missing entry
+// is built while streaming and only stored when the walk completes before ctx is cancelled.
+func (ds *DirectorySelector) streamRoot(ctx context.Context, root searchRoot, emit func(string)) {
+	if ds.cache == nil {
+		ds.searcher(root).SearchStream(ctx, root.path, root.depth, emit) //nolint:errcheck
+		return
+	}
+
+	if entry, ok := ds.cache.Load(root.path, root.depth, root.opts); ok {
+		ds.refreshes.Add(1)
+		go func() {
+			defer ds.refreshes.Done()
+			ds.cache.Refresh(root.path, root.depth, root.opts) //nolint:errcheck
+		}()
+		for _, dir := range entry.Dirs {
+			if ctx.Err() != nil {
+				return
+			}
+			emit(dir)
+		}
+		return
+	}
+
+	ds.cache.BuildStream(ctx, root.path, root.depth, root.opts, emit) //nolint:errcheck
+}
+
+// readableRoots drops the roots that cannot be read, so that failures surface before
+// the picker opens. It fails only if no root is left.
+func readableRoots(roots []searchRoot) ([]searchRoot, error) {
+	var readable []searchRoot
+	var firstErr error
+	for _, root := range roots {
+		info, err := os.Stat(root.path)
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("not a directory: %s", root.path)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if len(roots) > 1 {
+				color.Yellow("Skipping search path %s: %v", root.path, err)
+			}
+			continue
+		}
+		readable = append(readable, root)
+	}
+
+	if len(readable) == 0 {
+		return nil, firstErr
+	}
+	return readable, nil
+}
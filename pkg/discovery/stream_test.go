@@ -0,0 +1,174 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/index"
+)
+
+func collect(lines <-chan string) []string {
+	var result []string
+	for line := range lines {
+		result = append(result, line)
+	}
+	return result
+}
+
+func TestStreamListMatchesBuildList(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"git/api/src", "git/web", "work/tool", "work/other"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create test dir %s: %v", dir, err)
+		}
+	}
+
+	useZoxide := false
+	cfg := &config.Config{
+		UseZoxide: &useZoxide,
+		SearchPaths: []config.SearchPathConfig{
+			{Path: filepath.Join(tmpDir, "git"), Depth: 2},
+			{Path: filepath.Join(tmpDir, "work"), Label: "work"},
+			// Overlaps with the first path; its results must not be repeated
+			{Path: filepath.Join(tmpDir, "git", "api")},
+		},
+	}
+	ds := NewDirectorySelector(cfg)
+
+	built, err := ds.BuildList("", 0)
+	if err != nil {
+		t.Fatalf("BuildList() error = %v", err)
+	}
+
+	lines, err := ds.StreamList(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("StreamList() error = %v", err)
+	}
+	streamed := collect(lines)
+
+	// Streaming order depends on timing, so compare paths regardless of order and label
+	normalize := func(lines []string) []string {
+		var paths []string
+		for _, line := range lines {
+			paths = append(paths, ds.parseSelection(line))
+		}
+		slices.Sort(paths)
+		return paths
+	}
+	expected := normalize(strings.Split(string(built), "\n"))
+	if got := normalize(streamed); !slices.Equal(got, expected) {
+		t.Errorf("StreamList() = %v, want %v", got, expected)
+	}
+
+	for _, line := range streamed {
+		if strings.Contains(line, filepath.Join("work", "tool")) && !strings.HasPrefix(line, "[work] ") {
+			t.Errorf("Expected label on %q", line)
+		}
+	}
+}
+
+func TestStreamListCancel(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := range 200 {
+		if err := os.MkdirAll(filepath.Join(tmpDir, fmt.Sprintf("dir%03d", i), "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	useZoxide := false
+	ds := NewDirectorySelector(&config.Config{UseZoxide: &useZoxide})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, err := ds.StreamList(ctx, tmpDir, 0)
+	if err != nil {
+		t.Fatalf("StreamList() error = %v", err)
+	}
+
+	// Read a single line, then stop consuming like the picker does after a selection
+	if _, ok := <-lines; !ok {
+		t.Fatal("Expected at least one line")
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		collect(lines)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to close after cancellation")
+	}
+}
+
+func TestStreamListCancelUncached(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := range 200 {
+		if err := os.MkdirAll(filepath.Join(tmpDir, fmt.Sprintf("dir%03d", i), "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	useZoxide := false
+	ds := NewDirectorySelector(&config.Config{UseZoxide: &useZoxide})
+	ds.cache = index.NewCacheAt(t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, err := ds.StreamList(ctx, tmpDir, 2)
+	if err != nil {
+		t.Fatalf("StreamList() error = %v", err)
+	}
+	// More lines than the stream buffers, so the walk is still running when the picker goes away
+	if _, ok := <-lines; !ok {
+		t.Fatal("Expected at least one line")
+	}
+	cancel()
+	collect(lines)
+
+	// The walk building the missing entry stops with the picker, storing nothing
+	ds.WaitForRefresh()
+	if _, ok := ds.cache.Load(tmpDir, 2, searchOptions(ds.config)); ok {
+		t.Error("Expected no index entry after a cancelled walk")
+	}
+}
+
+func TestStreamListUnreadableRoots(t *testing.T) {
+	tmpDir := t.TempDir()
+	useZoxide := false
+
+	t.Run("AllMissing", func(t *testing.T) {
+		ds := NewDirectorySelector(&config.Config{UseZoxide: &useZoxide})
+		if _, err := ds.StreamList(context.Background(), filepath.Join(tmpDir, "missing"), 1); err == nil {
+			t.Error("Expected an error for a missing directory")
+		}
+	})
+
+	t.Run("SomeMissing", func(t *testing.T) {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "ok", "project"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		ds := NewDirectorySelector(&config.Config{
+			UseZoxide: &useZoxide,
+			SearchPaths: []config.SearchPathConfig{
+				{Path: filepath.Join(tmpDir, "missing")},
+				{Path: filepath.Join(tmpDir, "ok")},
+			},
+		})
+		lines, err := ds.StreamList(context.Background(), "", 1)
+		if err != nil {
+			t.Fatalf("StreamList() error = %v", err)
+		}
+		if got := collect(lines); len(got) != 1 {
+			t.Errorf("Expected results from the readable path only, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package frecency
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Zoxide queries and updates the zoxide database
+type Zoxide struct{}
+
+// Name returns the source name
+func (z *Zoxide) Name() string { return SourceZoxide }
+
+// List returns zoxide's directories with their scores
+func (z *Zoxide) List() ([]Entry, error) {
+	output, err := exec.Command("zoxide", "query", "--list", "--score").Output()
+	if err != nil {
+		return nil, err
+	}
+	// zoxide already returns them sorted by frecency
+	return parseScoredLines(output), nil
+}
+
+// Add records a visit to dir with zoxide
+func (z *Zoxide) Add(dir string) error {
+	return exec.Command("zoxide", "add", dir).Run()
+}
+
+// Autojump queries and updates the autojump database
+type Autojump struct{}
+
+// Name returns the source name
+func (a *Autojump) Name() string { return SourceAutojump }
+
+// List returns autojump's directories with their weights
+func (a *Autojump) List() ([]Entry, error) {
+	output, err := exec.Command("autojump", "--stat").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseAutojumpStat(output), nil
+}
+
+// Add records a visit to dir with autojump
+func (a *Autojump) Add(dir string) error {
+	return exec.Command("autojump", "--add", dir).Run()
+}
+
+// parseAutojumpStat extracts the entries from `autojump --stat` output, which lists
+// "weight:<tab>path" lines by ascending weight followed by a summary
+func parseAutojumpStat(output []byte) []Entry {
+	var entries []Entry
+	for _, line := range parseLines(output) {
+		weight, dir, ok := strings.Cut(line, ":\t")
+		if !ok || !strings.HasPrefix(dir, "/") {
+			continue
+		}
+		var score float64
+		if _, err := fmt.Sscan(weight, &score); err != nil {
+			continue
+		}
+		entries = append(entries, Entry{Dir: dir, Score: score})
+	}
+
+	// Highest weight first
+	slices.Reverse(entries)
+	return entries
+}
+
+// Fasd queries and updates the fasd database
+type Fasd struct{}
+
+// Name returns the source name
+func (f *Fasd) Name() string { return SourceFasd }
+
+// List returns fasd's directories with their scores
+func (f *Fasd) List() ([]Entry, error) {
+	// -d: directories only, -R: most frecent first
+	output, err := exec.Command("fasd", "-dR").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseScoredLines(output), nil
+}
+
+// Add records a visit to dir with fasd
+func (f *Fasd) Add(dir string) error {
+	return exec.Command("fasd", "-A", dir).Run()
+}
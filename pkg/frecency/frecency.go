@@ -0,0 +1,122 @@
+package frecency
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Names of the supported frecency sources
+const (
+	SourceZoxide   = "zoxide"
+	SourceAutojump = "autojump"
+	SourceFasd     = "fasd"
+	SourceZ        = "z"
+)
+
+// DefaultLimit caps how many frecent directories are suggested per search path
+const DefaultLimit = 30
+
+// Entry is a directory known to a frecency source with its score. Scores are only
+// comparable between entries of the same source.
+type Entry struct {
+	Dir   string
+	Score float64
+}
+
+// Source is a frecency database that can suggest directories and learn about new visits
+type Source interface {
+	// Name returns the source name, e.g. "zoxide"
+	Name() string
+	// List returns every directory known to the source, most frecent first
+	List() ([]Entry, error)
+	// Add records a visit to dir
+	Add(dir string) error
+}
+
+// QueryOptions controls which entries Under returns
+type QueryOptions struct {
+	Limit    int     // Maximum number of entries, 0 = unlimited
+	MinScore float64 // Entries scoring below this are dropped
+}
+
+// Names returns the names of all supported sources
+func Names() []string {
+	return []string{SourceZoxide, SourceAutojump, SourceFasd, SourceZ}
+}
+
+// New returns the source registered under name
+func New(name string) (Source, error) {
+	switch name {
+	case SourceZoxide:
+		return &Zoxide{}, nil
+	case SourceAutojump:
+		return &Autojump{}, nil
+	case SourceFasd:
+		return &Fasd{}, nil
+	case SourceZ:
+		return NewZFile(""), nil
+	}
+	return nil, fmt.Errorf("unknown frecency source %q (expected one of %s)", name, strings.Join(Names(), ", "))
+}
+
+// IsKnown reports whether name is a supported source
+func IsKnown(name string) bool {
+	return slices.Contains(Names(), name)
+}
+
+// Under keeps the entries strictly inside the directory path, preserving order.
+// Containment is checked per path segment, so /home/u/projects-old is not under
+// /home/u/projects.
+func Under(entries []Entry, path string, opts QueryOptions) []Entry {
+	var results []Entry
+	for _, e := range entries {
+		if e.Score < opts.MinScore || !isInside(e.Dir, path) {
+			continue
+		}
+		results = append(results, e)
+		if opts.Limit > 0 && len(results) == opts.Limit {
+			break
+		}
+	}
+	return results
+}
+
+// isInside reports whether dir is a descendant of parent
+func isInside(dir, parent string) bool {
+	rel, err := filepath.Rel(filepath.Clean(parent), filepath.Clean(dir))
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parseLines splits command output into trimmed, non-empty lines
+func parseLines(output []byte) []string {
+	var lines []string
+	for line := range strings.SplitSeq(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseScoredLines parses "score path" lines as printed by zoxide and fasd,
+// skipping lines that do not match
+func parseScoredLines(output []byte) []Entry {
+	var entries []Entry
+	for _, line := range parseLines(output) {
+		scoreField, dir, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		var score float64
+		if _, err := fmt.Sscan(scoreField, &score); err != nil {
+			continue
+		}
+		entries = append(entries, Entry{Dir: strings.TrimSpace(dir), Score: score})
+	}
+	return entries
+}
@@ -0,0 +1,193 @@
+package frecency
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		source, err := New(name)
+		if err != nil {
+			t.Fatalf("New(%q) error = %v", name, err)
+		}
+		if source.Name() != name {
+			t.Errorf("New(%q).Name() = %q", name, source.Name())
+		}
+	}
+
+	if _, err := New("jump"); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+	if IsKnown("jump") || !IsKnown(SourceZoxide) {
+		t.Error("IsKnown() does not match Names()")
+	}
+}
+
+func TestUnder(t *testing.T) {
+	entries := []Entry{
+		{Dir: "/home/u/projects", Score: 9},
+		{Dir: "/home/u/projects/a", Score: 8},
+		{Dir: "/home/u/projects-old", Score: 7},
+		{Dir: "/home/u/projects/b/", Score: 2},
+		{Dir: "/tmp/x", Score: 6},
+		{Dir: "/home/u/projects/c", Score: 1},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		opts     QueryOptions
+		expected []string
+	}{
+		{
+			name:     "SegmentContainment",
+			path:     "/home/u/projects",
+			expected: []string{"/home/u/projects/a", "/home/u/projects/b/", "/home/u/projects/c"},
+		},
+		{
+			name:     "TrailingSlashOnPath",
+			path:     "/home/u/projects/",
+			expected: []string{"/home/u/projects/a", "/home/u/projects/b/", "/home/u/projects/c"},
+		},
+		{
+			name:     "Limit",
+			path:     "/home/u/projects",
+			opts:     QueryOptions{Limit: 2},
+			expected: []string{"/home/u/projects/a", "/home/u/projects/b/"},
+		},
+		{
+			name:     "MinScore",
+			path:     "/home/u/projects",
+			opts:     QueryOptions{MinScore: 2},
+			expected: []string{"/home/u/projects/a", "/home/u/projects/b/"},
+		},
+		{
+			name:     "Root",
+			path:     "/",
+			opts:     QueryOptions{MinScore: 6.5},
+			expected: []string{"/home/u/projects", "/home/u/projects/a", "/home/u/projects-old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range Under(entries, tt.path, tt.opts) {
+				got = append(got, e.Dir)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Under(%q) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseScoredLines(t *testing.T) {
+	// zoxide right-aligns scores; fasd separates them with several spaces
+	output := []byte("  36.0 /home/u/projects/tmx\n   4.5 /home/u/my dir\n" +
+		"0.25       /srv/app\nnot-a-score /x\n")
+
+	expected := []Entry{
+		{Dir: "/home/u/projects/tmx", Score: 36},
+		{Dir: "/home/u/my dir", Score: 4.5},
+		{Dir: "/srv/app", Score: 0.25},
+	}
+	if got := parseScoredLines(output); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseScoredLines() = %v, want %v", got, expected)
+	}
+}
+
+func TestParseAutojumpStat(t *testing.T) {
+	output := []byte("10.0:\t/home/u/old\n" +
+		"22.4:\t/home/u/projects/tmx\n" +
+		"________________________________________\n\n" +
+		"32:\t total weight\n" +
+		"2:\t number of entries\n" +
+		"0.00:\t current directory weight\n\n" +
+		"data:\t /home/u/.local/share/autojump/autojump.txt\n")
+
+	expected := []Entry{{Dir: "/home/u/projects/tmx", Score: 22.4}, {Dir: "/home/u/old", Score: 10}}
+	if got := parseAutojumpStat(output); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseAutojumpStat() = %v, want %v", got, expected)
+	}
+}
+
+func TestZFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".z")
+	now := time.Now().Unix()
+	data := fmt.Sprintf("/p/old|50|%d\n/p/recent|5|%d\nmalformed line\n/elsewhere|9|%d\n", now-30*24*3600, now, now)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	z := NewZFile(path)
+
+	dirsUnder := func(z *ZFile, path string) []string {
+		t.Helper()
+		entries, err := z.List()
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		var dirs []string
+		for _, e := range Under(entries, path, QueryOptions{}) {
+			dirs = append(dirs, e.Dir)
+		}
+		return dirs
+	}
+
+	t.Run("ListOrdersByFrecency", func(t *testing.T) {
+		// A recent visit outweighs a high rank that has aged for a month
+		expected := []string{"/p/recent", "/p/old"}
+		if got := dirsUnder(z, "/p"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("List() = %v, want %v", got, expected)
+		}
+	})
+
+	t.Run("AddExisting", func(t *testing.T) {
+		if err := z.Add("/p/old"); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		entries, err := z.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.dir == "/p/old" && (e.rank != 51 || e.time < now) {
+				t.Errorf("Expected rank 51 and a fresh time, got %+v", e)
+			}
+		}
+		if len(entries) != 3 {
+			t.Errorf("Expected malformed lines to be dropped and no duplicate added, got %+v", entries)
+		}
+	})
+
+	t.Run("AddNew", func(t *testing.T) {
+		if err := z.Add("/p/new"); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if got := dirsUnder(z, "/p"); len(got) != 3 {
+			t.Errorf("Expected the new directory to be listed, got %v", got)
+		}
+	})
+
+	t.Run("AddCreatesFile", func(t *testing.T) {
+		fresh := NewZFile(filepath.Join(t.TempDir(), ".z"))
+		if err := fresh.Add("/p/first"); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if got := dirsUnder(fresh, "/p"); !reflect.DeepEqual(got, []string{"/p/first"}) {
+			t.Errorf("List() = %v, want [/p/first]", got)
+		}
+	})
+}
+
+func TestNewZFileDefaultPath(t *testing.T) {
+	t.Setenv("_Z_DATA", "/custom/z")
+	if z := NewZFile(""); z.path != "/custom/z" {
+		t.Errorf("Expected $_Z_DATA to be used, got %q", z.path)
+	}
+}
@@ -0,0 +1,128 @@
+package frecency
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZFile reads and updates the data file of z (https://github.com/rupa/z) directly
+type ZFile struct {
+	path string
+}
+
+// zEntry is a single "path|rank|time" line of a z data file
+type zEntry struct {
+	dir  string
+	rank float64
+	time int64
+}
+
+// NewZFile creates a ZFile for the data file at path. An empty path uses $_Z_DATA,
+// falling back to ~/.z.
+func NewZFile(path string) *ZFile {
+	if path == "" {
+		path = os.Getenv("_Z_DATA")
+	}
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".z")
+		}
+	}
+	return &ZFile{path: path}
+}
+
+// Name returns the source name
+func (z *ZFile) Name() string { return SourceZ }
+
+// List returns the directories ordered by z's frecency score
+func (z *ZFile) List() ([]Entry, error) {
+	entries, err := z.load()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	results := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		results = append(results, Entry{Dir: e.dir, Score: e.frecency(now)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results, nil
+}
+
+// Add increments the rank of dir and updates its access time, like `z --add`
+func (z *ZFile) Add(dir string) error {
+	entries, err := z.load()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	now := time.Now().Unix()
+	found := false
+	for i := range entries {
+		if entries[i].dir == dir {
+			entries[i].rank++
+			entries[i].time = now
+			found = true
+			break
+		}
+	}
+	if !found {
+		entries = append(entries, zEntry{dir: dir, rank: 1, time: now})
+	}
+
+	return z.store(entries)
+}
+
+// frecency mirrors the score z computes for its default (frecent) ordering
+func (e zEntry) frecency(now int64) float64 {
+	dx := float64(now - e.time)
+	return 10000 * e.rank * (3.75 / ((0.0001*dx + 1) + 0.25))
+}
+
+// load parses the data file, skipping malformed lines
+func (z *ZFile) load() ([]zEntry, error) {
+	data, err := os.ReadFile(z.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []zEntry
+	for _, line := range parseLines(data) {
+		parts := strings.Split(line, "|")
+		if len(parts) != 3 {
+			continue
+		}
+		rank, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			continue
+		}
+		accessed, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, zEntry{dir: parts[0], rank: rank, time: accessed})
+	}
+	return entries, nil
+}
+
+// store writes entries back to the data file through a temporary file, as z does
+func (z *ZFile) store(entries []zEntry) error {
+	var sb strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&sb, "%s|%s|%d\n", e.dir, strconv.FormatFloat(e.rank, 'f', -1, 64), e.time)
+	}
+
+	tmp := fmt.Sprintf("%s.%d", z.path, os.Getpid())
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, z.path)
+}
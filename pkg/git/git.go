@@ -0,0 +1,155 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned when a directory is not inside a git work tree
+var ErrNotRepository = errors.New("not a git repository")
+
+// Info describes the repository and worktree a directory belongs to
+type Info struct {
+	Repo     string // Repository name, derived from the main worktree (or bare repository) directory
+	Root     string // Top-level directory of the worktree containing the directory
+	Branch   string // Checked-out branch, or the abbreviated commit when HEAD is detached
+	Worktree bool   // True for linked worktrees, false for the main worktree
+}
+
+// Worktree is a single entry of `git worktree list`
+type Worktree struct {
+	Path     string
+	Head     string
+	Branch   string // Empty when HEAD is detached
+	Bare     bool
+	Detached bool
+}
+
+// Inspect returns the repository information for dir
+func Inspect(dir string) (*Info, error) {
+	out, err := run(dir, "rev-parse", "--path-format=absolute", "--show-toplevel", "--git-dir", "--git-common-dir")
+	if err != nil {
+		return nil, ErrNotRepository
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		return nil, fmt.Errorf("unexpected git rev-parse output: %q", out)
+	}
+	root, gitDir, commonDir := lines[0], lines[1], lines[2]
+
+	branch, err := currentBranch(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Info{
+		Repo:     repoName(commonDir),
+		Root:     root,
+		Branch:   branch,
+		Worktree: filepath.Clean(gitDir) != filepath.Clean(commonDir),
+	}, nil
+}
+
+// ListWorktrees returns every worktree of the repository containing dir
+func ListWorktrees(dir string) ([]Worktree, error) {
+	out, err := run(dir, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	return parseWorktreeList(out), nil
+}
+
+// AddWorktree creates a worktree for branch at path, creating the branch from the
+// current HEAD if it does not exist yet
+func AddWorktree(dir, branch, path string) error {
+	if _, err := run(dir, "show-ref", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		_, err := run(dir, "worktree", "add", path, branch)
+		return err
+	}
+	_, err := run(dir, "worktree", "add", "-b", branch, path)
+	return err
+}
+
+// DefaultWorktreePath returns where a new worktree for branch is created: a sibling
+// of the main worktree named "<repo>-<branch>"
+func DefaultWorktreePath(mainWorktree, branch string) string {
+	name := filepath.Base(mainWorktree) + "-" + strings.ReplaceAll(branch, "/", "-")
+	return filepath.Join(filepath.Dir(mainWorktree), name)
+}
+
+// currentBranch returns the checked-out branch, or the abbreviated commit when detached
+func currentBranch(dir string) (string, error) {
+	if out, err := run(dir, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		return strings.TrimSpace(out), nil
+	}
+	out, err := run(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		// A repository without commits has no HEAD to describe
+		return "", nil
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// repoName derives the repository name from its common git directory:
+// /src/tmx/.git -> tmx, /src/tmx.git -> tmx
+func repoName(commonDir string) string {
+	commonDir = filepath.Clean(commonDir)
+	if filepath.Base(commonDir) == ".git" {
+		return filepath.Base(filepath.Dir(commonDir))
+	}
+	return strings.TrimSuffix(filepath.Base(commonDir), ".git")
+}
+
+// parseWorktreeList parses the output of `git worktree list --porcelain`
+func parseWorktreeList(out string) []Worktree {
+	var worktrees []Worktree
+	var current *Worktree
+
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch key {
+		case "worktree":
+			worktrees = append(worktrees, Worktree{Path: value})
+			current = &worktrees[len(worktrees)-1]
+		case "HEAD":
+			if current != nil {
+				current.Head = value
+			}
+		case "branch":
+			if current != nil {
+				current.Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		case "bare":
+			if current != nil {
+				current.Bare = true
+			}
+		case "detached":
+			if current != nil {
+				current.Detached = true
+			}
+		}
+	}
+
+	return worktrees
+}
+
+// run executes git in dir and returns its standard output
+func run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,144 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// initRepo creates a repository with one commit on main inside a fresh temp dir
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	// git reports resolved paths, so resolve the temp dir as well
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(tmp, "project")
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	gitCmds := [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+	}
+	for _, args := range gitCmds {
+		if _, err := run(root, args...); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	return root
+}
+
+func TestInspect(t *testing.T) {
+	root := initRepo(t)
+
+	t.Run("MainWorktree", func(t *testing.T) {
+		info, err := Inspect(filepath.Join(root, "sub"))
+		if err != nil {
+			t.Fatalf("Inspect() error = %v", err)
+		}
+		expected := &Info{Repo: "project", Root: root, Branch: "main"}
+		if !reflect.DeepEqual(info, expected) {
+			t.Errorf("Inspect() = %+v, want %+v", info, expected)
+		}
+	})
+
+	t.Run("LinkedWorktree", func(t *testing.T) {
+		path := DefaultWorktreePath(root, "feature/login")
+		if err := AddWorktree(root, "feature/login", path); err != nil {
+			t.Fatalf("AddWorktree() error = %v", err)
+		}
+
+		info, err := Inspect(path)
+		if err != nil {
+			t.Fatalf("Inspect() error = %v", err)
+		}
+		expected := &Info{Repo: "project", Root: path, Branch: "feature/login", Worktree: true}
+		if !reflect.DeepEqual(info, expected) {
+			t.Errorf("Inspect() = %+v, want %+v", info, expected)
+		}
+	})
+
+	t.Run("NotARepository", func(t *testing.T) {
+		if _, err := Inspect(t.TempDir()); err != ErrNotRepository {
+			t.Errorf("Inspect() error = %v, want %v", err, ErrNotRepository)
+		}
+	})
+}
+
+func TestListWorktrees(t *testing.T) {
+	root := initRepo(t)
+
+	if _, err := run(root, "branch", "existing"); err != nil {
+		t.Fatal(err)
+	}
+	existing := DefaultWorktreePath(root, "existing")
+	if err := AddWorktree(root, "existing", existing); err != nil {
+		t.Fatalf("AddWorktree() for an existing branch error = %v", err)
+	}
+
+	worktrees, err := ListWorktrees(existing)
+	if err != nil {
+		t.Fatalf("ListWorktrees() error = %v", err)
+	}
+
+	if len(worktrees) != 2 {
+		t.Fatalf("Expected 2 worktrees, got %+v", worktrees)
+	}
+	if worktrees[0].Path != root || worktrees[0].Branch != "main" {
+		t.Errorf("Expected the main worktree first, got %+v", worktrees[0])
+	}
+	if worktrees[1].Path != existing || worktrees[1].Branch != "existing" {
+		t.Errorf("Expected the linked worktree second, got %+v", worktrees[1])
+	}
+}
+
+func TestParseWorktreeList(t *testing.T) {
+	out := `worktree /src/tmx.git
+bare
+
+worktree /src/tmx-main
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /src/tmx-review
+HEAD 2222222222222222222222222222222222222222
+detached
+`
+	expected := []Worktree{
+		{Path: "/src/tmx.git", Bare: true},
+		{Path: "/src/tmx-main", Head: "1111111111111111111111111111111111111111", Branch: "main"},
+		{Path: "/src/tmx-review", Head: "2222222222222222222222222222222222222222", Detached: true},
+	}
+
+	if got := parseWorktreeList(out); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseWorktreeList() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestRepoName(t *testing.T) {
+	tests := map[string]string{
+		"/src/tmx/.git": "tmx",
+		"/src/tmx.git":  "tmx",
+		"/src/bare":     "bare",
+	}
+	for commonDir, expected := range tests {
+		if got := repoName(commonDir); got != expected {
+			t.Errorf("repoName(%q) = %q, want %q", commonDir, got, expected)
+		}
+	}
+}
+
+func TestDefaultWorktreePath(t *testing.T) {
+	if got := DefaultWorktreePath("/src/tmx", "feature/login"); got != "/src/tmx-feature-login" {
+		t.Errorf("DefaultWorktreePath() = %q, want %q", got, "/src/tmx-feature-login")
+	}
+}
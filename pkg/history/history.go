@@ -3,6 +3,7 @@ package history
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -51,6 +52,39 @@ func Record(sessionName string, max int) {
 	os.WriteFile(path, []byte(content), 0o644) //nolint:errcheck
 }
 
+// Rename replaces oldName with newName in the history file, so that a renamed session
+// keeps its place among the recent sessions
+func Rename(oldName, newName string) error {
+	path, err := filePath()
+	if err != nil {
+		return err
+	}
+	return rename(path, oldName, newName)
+}
+
+// rename replaces oldName with newName in the history file at path. Entries left by an
+// earlier session called newName are dropped.
+func rename(path, oldName, newName string) error {
+	entries := load(path)
+	if !slices.Contains(entries, oldName) {
+		return nil
+	}
+
+	var renamed []string
+	for _, e := range entries {
+		switch e {
+		case newName:
+			continue
+		case oldName:
+			e = newName
+		}
+		renamed = append(renamed, e)
+	}
+
+	content := strings.Join(renamed, "\n") + "\n"
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
 // Load returns up to max recent session names, newest first.
 func Load(max int) []string {
 	path, err := filePath()
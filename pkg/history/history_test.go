@@ -147,6 +147,48 @@ func TestLoadPublic_RespectsMax(t *testing.T) {
 	}
 }
 
+func TestRename_ReplacesEntryInPlace(t *testing.T) {
+	path := tempHistoryFile(t)
+	writeHistory(t, path, []string{"alpha", "beta", "gamma"})
+
+	if err := rename(path, "beta", "delta"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := load(path)
+	if len(entries) != 3 || entries[0] != "alpha" || entries[1] != "delta" || entries[2] != "gamma" {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+}
+
+func TestRename_DropsStaleNewName(t *testing.T) {
+	path := tempHistoryFile(t)
+	writeHistory(t, path, []string{"delta", "alpha", "beta"})
+
+	if err := rename(path, "alpha", "delta"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := load(path)
+	if len(entries) != 2 || entries[0] != "delta" || entries[1] != "beta" {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+}
+
+func TestRename_UnknownSessionLeavesFile(t *testing.T) {
+	path := tempHistoryFile(t)
+	writeHistory(t, path, []string{"alpha"})
+
+	if err := rename(path, "beta", "gamma"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := load(path)
+	if len(entries) != 1 || entries[0] != "alpha" {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+}
+
 // recordTo is a test helper that records to a specific file path
 // instead of the real home-based path.
 func recordTo(path, sessionName string, max int) {
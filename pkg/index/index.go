@@ -0,0 +1,164 @@
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vbrdnk/tmx/pkg/search"
+)
+
+const defaultIndexDir = "tmx/index"
+
+// Entry is the cached result of searching one root
+type Entry struct {
+	Root    string               `json:"root"`
+	Depth   int                  `json:"depth"`
+	Dirs    []string             `json:"dirs"`
+	Stamps  map[string]time.Time `json:"stamps"` // Modification times of everything read while searching
+	BuiltAt time.Time            `json:"built_at"`
+}
+
+// Stale reports whether any directory or ignore file read while building the entry
+// has been modified, created or removed since
+func (e *Entry) Stale() bool {
+	for path, modTime := range e.Stamps {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cache stores search results on disk, one file per root, depth and option set
+type Cache struct {
+	dir string
+}
+
+// NewCache creates a Cache in the user's cache directory (e.g. ~/.cache/tmx/index)
+func NewCache() (*Cache, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewCacheAt(filepath.Join(cacheDir, defaultIndexDir)), nil
+}
+
+// NewCacheAt creates a Cache storing its files in dir
+func NewCacheAt(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Load returns the cached entry for root, depth and opts, if any
+func (c *Cache) Load(root string, depth int, opts search.Options) (*Entry, bool) {
+	data, err := os.ReadFile(c.filePath(root, depth, opts))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Build searches root with the in-process walker and stores the result
+func (c *Cache) Build(root string, depth int, opts search.Options) (*Entry, error) {
+	dirs, stamps, err := search.NewWalker(opts).WalkStamped(root, depth)
+	if err != nil {
+		return nil, err
+	}
+	return c.save(root, depth, opts, dirs, stamps)
+}
+
+// BuildStream behaves like Build but also passes each directory to emit as soon as
+// it is found. Nothing is stored if ctx is cancelled before the walk completes.
+func (c *Cache) BuildStream(ctx context.Context, root string, depth int, opts search.Options, emit func(string)) (*Entry, error) {
+	var dirs []string
+	stamps, err := search.NewWalker(opts).WalkStream(ctx, root, depth, func(dir string) {
+		dirs = append(dirs, dir)
+		emit(dir)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(dirs)
+	return c.save(root, depth, opts, dirs, stamps)
+}
+
+// save stores the results of a completed walk
+func (c *Cache) save(root string, depth int, opts search.Options, dirs []string, stamps map[string]time.Time) (*Entry, error) {
+	entry := &Entry{
+		Root:    filepath.Clean(root),
+		Depth:   depth,
+		Dirs:    dirs,
+		Stamps:  stamps,
+		BuiltAt: time.Now(),
+	}
+	if err := c.store(c.filePath(root, depth, opts), entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Refresh rebuilds the entry for root when it is missing or stale. It reports
+// whether a rebuild happened.
+func (c *Cache) Refresh(root string, depth int, opts search.Options) (bool, error) {
+	if entry, ok := c.Load(root, depth, opts); ok && !entry.Stale() {
+		return false, nil
+	}
+	if _, err := c.Build(root, depth, opts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Clear removes every cached entry
+func (c *Cache) Clear() error {
+	return os.RemoveAll(c.dir)
+}
+
+// store writes entry atomically so that concurrent readers never see a partial file
+func (c *Cache) store(path string, entry *Entry) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// filePath returns the cache file for a root, depth and option set
+func (c *Cache) filePath(root string, depth int, opts search.Options) string {
+	// Workers only affects speed, not results
+	opts.Workers = 0
+	key := fmt.Sprintf("%s\x00%d\x00%#v", filepath.Clean(root), depth, opts)
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+".json")
+}
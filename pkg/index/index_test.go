@@ -0,0 +1,153 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vbrdnk/tmx/pkg/search"
+)
+
+func createTree(t *testing.T, dirs ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("Failed to create test dir %s: %v", dir, err)
+		}
+	}
+	return root
+}
+
+// touchPast moves the modification time of path into the past so that a later
+// change is detected even on filesystems with coarse timestamps
+func touchPast(t *testing.T, path string) {
+	t.Helper()
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildAndLoad(t *testing.T) {
+	root := createTree(t, "a/b", "c")
+	cache := NewCacheAt(t.TempDir())
+
+	if _, ok := cache.Load(root, 0, search.Options{}); ok {
+		t.Fatal("Expected no entry before building")
+	}
+
+	built, err := cache.Build(root, 0, search.Options{})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(built.Dirs) != 3 {
+		t.Errorf("Expected 3 directories, got %v", built.Dirs)
+	}
+
+	loaded, ok := cache.Load(root, 0, search.Options{})
+	if !ok {
+		t.Fatal("Expected an entry after building")
+	}
+	if len(loaded.Dirs) != len(built.Dirs) || loaded.Root != root {
+		t.Errorf("Loaded entry %+v does not match built entry %+v", loaded, built)
+	}
+	if loaded.Stale() {
+		t.Error("Expected a freshly built entry not to be stale")
+	}
+}
+
+func TestEntryKeys(t *testing.T) {
+	root := createTree(t, "a/b")
+	cache := NewCacheAt(t.TempDir())
+
+	if _, err := cache.Build(root, 1, search.Options{}); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if _, ok := cache.Load(root, 2, search.Options{}); ok {
+		t.Error("Expected a different depth to miss the cache")
+	}
+	if _, ok := cache.Load(root, 1, search.Options{ExcludeHidden: true}); ok {
+		t.Error("Expected different options to miss the cache")
+	}
+	if _, ok := cache.Load(root, 1, search.Options{Workers: 3}); !ok {
+		t.Error("Expected the worker count not to affect the cache key")
+	}
+}
+
+func TestStaleAndRefresh(t *testing.T) {
+	root := createTree(t, "a/b")
+	touchPast(t, filepath.Join(root, "a"))
+	cache := NewCacheAt(t.TempDir())
+
+	if _, err := cache.Build(root, 0, search.Options{}); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	rebuilt, err := cache.Refresh(root, 0, search.Options{})
+	if err != nil || rebuilt {
+		t.Fatalf("Refresh() = %v, %v; want no rebuild for a fresh entry", rebuilt, err)
+	}
+
+	// Adding a directory changes the parent's modification time
+	if err := os.Mkdir(filepath.Join(root, "a", "new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, _ := cache.Load(root, 0, search.Options{})
+	if !entry.Stale() {
+		t.Fatal("Expected the entry to be stale after adding a directory")
+	}
+
+	rebuilt, err = cache.Refresh(root, 0, search.Options{})
+	if err != nil || !rebuilt {
+		t.Fatalf("Refresh() = %v, %v; want a rebuild for a stale entry", rebuilt, err)
+	}
+
+	entry, _ = cache.Load(root, 0, search.Options{})
+	if len(entry.Dirs) != 3 {
+		t.Errorf("Expected the refreshed entry to contain the new directory, got %v", entry.Dirs)
+	}
+}
+
+func TestStaleOnIgnoreFileChange(t *testing.T) {
+	root := createTree(t, "a", "b")
+	ignoreFile := filepath.Join(root, ".gitignore")
+	if err := os.WriteFile(ignoreFile, []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	touchPast(t, ignoreFile)
+
+	cache := NewCacheAt(t.TempDir())
+	opts := search.Options{RespectIgnore: true}
+	if _, err := cache.Build(root, 0, opts); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	// Rewriting the file in place leaves the directory's modification time untouched
+	if err := os.WriteFile(ignoreFile, []byte("b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, _ := cache.Load(root, 0, opts)
+	if !entry.Stale() {
+		t.Error("Expected the entry to be stale after editing an ignore file")
+	}
+}
+
+func TestClear(t *testing.T) {
+	root := createTree(t, "a")
+	cache := NewCacheAt(filepath.Join(t.TempDir(), "index"))
+
+	if _, err := cache.Build(root, 0, search.Options{}); err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if _, ok := cache.Load(root, 0, search.Options{}); ok {
+		t.Error("Expected no entry after clearing")
+	}
+}
@@ -0,0 +1,187 @@
+package search
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ignoreFiles lists the per-directory files whose rules are honoured when ignore
+// support is enabled, in the order they are applied
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// ignoreRule is a single compiled gitignore-style pattern
+type ignoreRule struct {
+	base     string // slash-separated directory the rule is relative to ("" = search root)
+	negate   bool
+	anchored bool // the pattern contains a slash and matches against the full relative path
+	re       *regexp.Regexp
+}
+
+// ignoreRules is an ordered list of rules; later rules take precedence
+type ignoreRules []ignoreRule
+
+// compileRule parses a gitignore-style pattern relative to base. It returns false
+// for blank lines and comments.
+func compileRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// An escaped leading "!" or "#" is matched literally
+		line = line[1:]
+	}
+
+	// Only directories are searched, so a trailing slash changes nothing
+	line = strings.TrimSuffix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	rule.re = globToRegexp(line)
+	return rule, true
+}
+
+// match reports whether the rule applies to the directory at rel, the slash-separated
+// path relative to the search root
+func (r ignoreRule) match(rel string) bool {
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+
+	if r.anchored {
+		return r.re.MatchString(rel)
+	}
+	return r.re.MatchString(path.Base(rel))
+}
+
+// matches reports whether rel is ignored: the last rule matching it decides
+func (rules ignoreRules) matches(rel string) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.match(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// with returns a new rule list extending rules with extra, leaving rules untouched so
+// that sibling directories can safely share it
+func (rules ignoreRules) with(extra ignoreRules) ignoreRules {
+	if len(extra) == 0 {
+		return rules
+	}
+	combined := make(ignoreRules, 0, len(rules)+len(extra))
+	combined = append(combined, rules...)
+	return append(combined, extra...)
+}
+
+// compilePatterns compiles patterns relative to the search root
+func compilePatterns(patterns []string) ignoreRules {
+	var rules ignoreRules
+	for _, p := range patterns {
+		if rule, ok := compileRule("", p); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// readIgnoreFile loads the rules from an ignore file located in the directory base.
+// Missing or unreadable files yield no rules.
+func readIgnoreFile(filePath, base string) ignoreRules {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules ignoreRules
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := compileRule(base, scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// globToRegexp converts a gitignore glob into an anchored regular expression.
+// "*" and "?" never cross a slash, "**" spans any number of path segments.
+// Patterns that cannot be compiled fall back to a literal match.
+func globToRegexp(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				atStart := i == 0 || glob[i-1] == '/'
+				rest := glob[i+2:]
+				switch {
+				case atStart && strings.HasPrefix(rest, "/"):
+					// "**/" matches zero or more leading directories
+					sb.WriteString("(?:.*/)?")
+					i += 2
+				case atStart && rest == "":
+					// A trailing "/**" matches everything inside
+					sb.WriteString(".*")
+					i++
+				default:
+					sb.WriteString("[^/]*")
+					i++
+				}
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(glob) + "$")
+	}
+	return re
+}
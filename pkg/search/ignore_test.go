@@ -0,0 +1,58 @@
+package search
+
+import "testing"
+
+func TestIgnoreRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		rel      string
+		expected bool
+	}{
+		{name: "Name at root", patterns: []string{"target"}, rel: "target", expected: true},
+		{name: "Name nested", patterns: []string{"target"}, rel: "a/b/target", expected: true},
+		{name: "Trailing slash", patterns: []string{"dist/"}, rel: "web/dist", expected: true},
+		{name: "Anchored at root", patterns: []string{"/vendor"}, rel: "vendor", expected: true},
+		{name: "Anchored not nested", patterns: []string{"/vendor"}, rel: "x/vendor", expected: false},
+		{name: "Inner slash anchors", patterns: []string{"a/b"}, rel: "x/a/b", expected: false},
+		{name: "Star within segment", patterns: []string{".venv*"}, rel: "py/.venv3", expected: true},
+		{name: "Star does not cross slash", patterns: []string{"/a/*"}, rel: "a/b/c", expected: false},
+		{name: "Leading double star", patterns: []string{"**/build"}, rel: "a/b/build", expected: true},
+		{name: "Leading double star at root", patterns: []string{"**/build"}, rel: "build", expected: true},
+		{name: "Middle double star", patterns: []string{"a/**/c"}, rel: "a/x/y/c", expected: true},
+		{name: "Trailing double star", patterns: []string{"a/**"}, rel: "a/b/c", expected: true},
+		{name: "Question mark", patterns: []string{"tm?"}, rel: "tmp", expected: true},
+		{name: "Character class", patterns: []string{"v[0-9]"}, rel: "v1", expected: true},
+		{name: "Negated class", patterns: []string{"v[!0-9]"}, rel: "v1", expected: false},
+		{name: "Unclosed class is literal", patterns: []string{"a["}, rel: "a[", expected: true},
+		{name: "Negation wins when last", patterns: []string{"*", "!keep"}, rel: "keep", expected: false},
+		{name: "Later rule wins", patterns: []string{"!keep", "keep"}, rel: "keep", expected: true},
+		{name: "Comment ignored", patterns: []string{"# keep"}, rel: "# keep", expected: false},
+		{name: "Escaped hash", patterns: []string{`\#notes`}, rel: "#notes", expected: true},
+		{name: "Dots are literal", patterns: []string{"a.b"}, rel: "axb", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := compilePatterns(tt.patterns)
+			if got := rules.matches(tt.rel); got != tt.expected {
+				t.Errorf("matches(%q) with %v = %v, want %v", tt.rel, tt.patterns, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIgnoreRulesRelativeToBase(t *testing.T) {
+	rule, ok := compileRule("app", "/target")
+	if !ok {
+		t.Fatal("compileRule() rejected a valid pattern")
+	}
+	rules := ignoreRules{rule}
+
+	if !rules.matches("app/target") {
+		t.Error("Expected app/target to be ignored by a rule from app/.gitignore")
+	}
+	if rules.matches("target") || rules.matches("other/app/target") {
+		t.Error("Expected rule from app/.gitignore not to apply outside app")
+	}
+}
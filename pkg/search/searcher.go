@@ -1,115 +1,149 @@
 package search
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 )
 
 // DirectorySearcher handles directory discovery using various tools
 type DirectorySearcher struct {
 	fdAvailable bool
+	opts        Options
+	walker      *Walker
 }
 
-// NewDirectorySearcher creates a new DirectorySearcher instance
-func NewDirectorySearcher() *DirectorySearcher {
+// NewDirectorySearcher creates a new DirectorySearcher instance, looking fd up in PATH
+func NewDirectorySearcher(opts Options) *DirectorySearcher {
+	return NewDirectorySearcherWithFd(opts, FdAvailable())
+}
+
+// NewDirectorySearcherWithFd creates a DirectorySearcher that uses fd when fdAvailable,
+// for callers creating several searchers that look fd up only once
+func NewDirectorySearcherWithFd(opts Options, fdAvailable bool) *DirectorySearcher {
 	return &DirectorySearcher{
-		fdAvailable: isFdAvailable(),
+		fdAvailable: fdAvailable,
+		opts:        opts,
+		walker:      NewWalker(opts),
 	}
 }
 
-// Search uses fd (with fallback to find) to discover directories
+// Search discovers directories with the in-process walker, using fd as an
+// accelerator when it is installed. Both backends return the same, sorted results.
 func (ds *DirectorySearcher) Search(path string, depth int) ([]string, error) {
-	if ds.fdAvailable {
-		return ds.performFdSearch(path, depth)
+	if ds.canUseFd() {
+		if results, err := ds.performFdSearch(path, depth); err == nil {
+			return results, nil
+		}
+		// Fall through to the walker if fd fails for any reason
 	}
-	return ds.performFindSearch(path, depth)
+	return ds.walker.Walk(path, depth)
 }
 
-// QueryZoxideCache queries zoxide for frecent directories under the given path
-func (ds *DirectorySearcher) QueryZoxideCache(path string) ([]string, error) {
-	cmd := exec.Command("zoxide", "query", "--list")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	var results []string
-	lines := strings.SplitSeq(string(output), "\n")
-
-	// Filter results to only include paths under the target path
-	for line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && strings.HasPrefix(line, path) && line != path {
-			results = append(results, line)
+// SearchStream behaves like Search but passes each directory to emit as soon as it
+// is found, in no particular order. The search stops early when ctx is cancelled.
+// If fd fails part-way, the walker takes over and may emit some directories again.
+func (ds *DirectorySearcher) SearchStream(ctx context.Context, path string, depth int, emit func(string)) error {
+	if ds.canUseFd() {
+		if err := ds.streamFdSearch(ctx, path, depth, emit); err == nil || ctx.Err() != nil {
+			return ctx.Err()
 		}
 	}
+	_, err := ds.walker.WalkStream(ctx, path, depth, emit)
+	return err
+}
 
-	// Limit to top 30 results (zoxide already returns them sorted by frecency)
-	if len(results) > 30 {
-		results = results[:30]
-	}
+// canUseFd reports whether fd is installed and can reproduce the walker's results.
+// fd has no equivalent for include patterns, negated excludes or project detection, and
+// its ignore file handling (global excludes, parent directories, .fdignore) differs from
+// the walker's.
+func (ds *DirectorySearcher) canUseFd() bool {
+	return ds.fdAvailable && !ds.opts.RespectIgnore && len(ds.opts.Includes) == 0 && !ds.opts.ProjectsOnly &&
+		!hasNegatedPattern(ds.opts.Excludes)
+}
 
-	return results, nil
+// hasNegatedPattern reports whether any of patterns re-includes paths with a leading `!`,
+// which fd --exclude would take as a literal glob
+func hasNegatedPattern(patterns []string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		return strings.HasPrefix(pattern, "!")
+	})
 }
 
 // performFdSearch uses fd to find directories
 func (ds *DirectorySearcher) performFdSearch(path string, depth int) ([]string, error) {
-	args := []string{
-		"--type", "d",
-		"--hidden",
-		"--exclude", ".git",
-		"--exclude", "node_modules",
-		"--exclude", ".DS_Store",
-	}
-
-	// Add depth constraint if not unlimited
-	if depth > 0 {
-		args = append(args, "--max-depth", fmt.Sprintf("%d", depth))
+	cmd := exec.Command("fd", ds.fdArgs(path, depth)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error executing fd command: %v", err)
 	}
 
-	args = append(args, ".", path)
+	results := parseDirectoryOutput(output)
+	sort.Strings(results)
+	return results, nil
+}
 
-	cmd := exec.Command("fd", args...)
-	output, err := cmd.Output()
+// streamFdSearch runs fd and passes each directory to emit as fd prints it
+func (ds *DirectorySearcher) streamFdSearch(ctx context.Context, path string, depth int, emit func(string)) error {
+	cmd := exec.CommandContext(ctx, "fd", ds.fdArgs(path, depth)...)
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return nil, fmt.Errorf("error executing fd command: %v", err)
+		return fmt.Errorf("failed to create stdout pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error executing fd command: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			emit(filepath.Clean(line))
+		}
 	}
 
-	return parseDirectoryOutput(output), nil
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("error executing fd command: %v", err)
+	}
+	return nil
 }
 
-// performFindSearch uses classic find command
-func (ds *DirectorySearcher) performFindSearch(path string, depth int) ([]string, error) {
+// fdArgs builds the fd arguments reproducing the walker's behaviour
+func (ds *DirectorySearcher) fdArgs(path string, depth int) []string {
+	// Mirror the walker: disregard ignore files and follow symlinks
 	args := []string{
-		path,
-		"-mindepth", "1",
-		"-type", "d",
-		"-not", "-path", "*/.*",
-		"-not", "-path", "*/node_modules/*",
+		"--type", "d",
+		"--no-ignore",
+		"--follow",
+		"--color", "never",
 	}
-
-	// Add maxdepth constraint if not unlimited
-	if depth > 0 {
-		args = append(args, "-maxdepth", fmt.Sprintf("%d", depth))
+	if !ds.opts.ExcludeHidden {
+		args = append(args, "--hidden")
+	}
+	for _, pattern := range append(slices.Clone(DefaultExcludes), ds.opts.Excludes...) {
+		args = append(args, "--exclude", pattern)
 	}
 
-	cmd := exec.Command("find", args...)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("error executing find command: %v", err)
+	// Add depth constraint if not unlimited
+	if depth > 0 {
+		args = append(args, "--max-depth", fmt.Sprintf("%d", depth))
 	}
 
-	return parseDirectoryOutput(output), nil
+	return append(args, ".", path)
 }
 
-// isFdAvailable checks if fd is installed and available in PATH
-func isFdAvailable() bool {
+// FdAvailable checks if fd is installed and available in PATH
+func FdAvailable() bool {
 	_, err := exec.LookPath("fd")
 	return err == nil
 }
 
-// parseDirectoryOutput splits command output into directory paths
+// parseDirectoryOutput splits command output into cleaned directory paths.
+// Newer fd versions print directories with a trailing separator, which is stripped here.
 func parseDirectoryOutput(output []byte) []string {
 	var results []string
 	lines := strings.SplitSeq(string(output), "\n")
@@ -117,7 +151,7 @@ func parseDirectoryOutput(output []byte) []string {
 	for line := range lines {
 		line = strings.TrimSpace(line)
 		if line != "" {
-			results = append(results, line)
+			results = append(results, filepath.Clean(line))
 		}
 	}
 
@@ -0,0 +1,329 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExcludes lists directory names that are never descended into or reported
+var DefaultExcludes = []string{".git", "node_modules", ".DS_Store"}
+
+// DefaultProjectMarkers lists the files and directories that identify a project root
+var DefaultProjectMarkers = []string{".git", "go.mod", "package.json", "Cargo.toml", ".tmx.toml"}
+
+// Options controls which directories a search reports
+type Options struct {
+	// Excludes are gitignore-style patterns for directories to skip, in addition
+	// to DefaultExcludes. Patterns without a slash match a directory name at any
+	// depth; patterns with a slash match the path relative to the search root.
+	Excludes []string
+	// Includes are gitignore-style patterns for directories to report even when
+	// they are hidden, excluded or ignored. DefaultExcludes always apply.
+	Includes []string
+	// ExcludeHidden skips directories whose name starts with a dot
+	ExcludeHidden bool
+	// RespectIgnore honours .gitignore and .ignore files found below the search root
+	RespectIgnore bool
+	// ProjectsOnly reports only directories containing one of ProjectMarkers and
+	// does not descend into a project once it is found
+	ProjectsOnly bool
+	// ProjectMarkers overrides DefaultProjectMarkers when set
+	ProjectMarkers []string
+	// Workers bounds how many directories are read concurrently (default: number of CPUs)
+	Workers int
+}
+
+// Walker is a concurrent, in-process directory walker. It reports every directory
+// below the root that passes its Options, follows symlinked directories and refuses
+// to re-enter a directory that is already one of its ancestors.
+type Walker struct {
+	opts     Options
+	excludes ignoreRules
+	includes ignoreRules
+}
+
+// NewWalker creates a Walker with the given options
+func NewWalker(opts Options) *Walker {
+	if opts.Workers < 1 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if len(opts.ProjectMarkers) == 0 {
+		opts.ProjectMarkers = DefaultProjectMarkers
+	}
+
+	return &Walker{
+		opts:     opts,
+		excludes: compilePatterns(opts.Excludes),
+		includes: compilePatterns(opts.Includes),
+	}
+}
+
+// walkState holds the shared state of a single Walk call
+type walkState struct {
+	ctx      context.Context
+	walker   *Walker
+	maxDepth int
+	sem      chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	emit    func(string) // when set, receives results instead of collecting them
+	results []string
+	stamps  map[string]time.Time
+}
+
+// Walk returns all directories below root, up to depth levels deep (0 = unlimited),
+// or only the project roots among them in ProjectsOnly mode. Results are sorted.
+// Unreadable subdirectories are skipped silently; only a failure to read the root
+// itself is reported.
+func (w *Walker) Walk(root string, depth int) ([]string, error) {
+	results, _, err := w.WalkStamped(root, depth)
+	return results, err
+}
+
+// WalkStamped behaves like Walk and additionally returns the modification time of
+// every directory and ignore file that was read. The results remain valid for as
+// long as none of those modification times change.
+func (w *Walker) WalkStamped(root string, depth int) ([]string, map[string]time.Time, error) {
+	state, err := w.walk(context.Background(), root, depth, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(state.results)
+	return state.results, state.stamps, nil
+}
+
+// WalkStream behaves like WalkStamped but passes each directory to emit as soon as it
+// is found, in no particular order, instead of returning them. Calls to emit are
+// serialized. The walk stops early when ctx is cancelled.
+func (w *Walker) WalkStream(ctx context.Context, root string, depth int, emit func(string)) (map[string]time.Time, error) {
+	state, err := w.walk(ctx, root, depth, emit)
+	if err != nil {
+		return nil, err
+	}
+	return state.stamps, nil
+}
+
+// walk runs a complete traversal of root and returns its final state
+func (w *Walker) walk(ctx context.Context, root string, depth int, emit func(string)) (*walkState, error) {
+	root = filepath.Clean(root)
+
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail early when the root cannot be listed at all
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &walkState{
+		ctx:      ctx,
+		walker:   w,
+		maxDepth: depth,
+		sem:      make(chan struct{}, w.opts.Workers),
+		emit:     emit,
+		stamps:   make(map[string]time.Time),
+	}
+
+	state.wg.Add(1)
+	state.visitEntries(root, "", entries, 1, []os.FileInfo{rootInfo}, nil)
+	state.wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// visit reads dir and walks its children. rel is dir relative to the root, ancestors
+// holds the file info of every directory from the root to dir inclusive, and ignored
+// holds the ignore file rules collected from those directories.
+func (s *walkState) visit(dir, rel string, depth int, ancestors []os.FileInfo, ignored ignoreRules) {
+	if s.ctx.Err() != nil {
+		s.wg.Done()
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		s.wg.Done()
+		return
+	}
+	s.visitEntries(dir, rel, entries, depth, ancestors, ignored)
+}
+
+// visitEntries records the subdirectories found in entries and schedules their traversal.
+// Each subdirectory is handed to a new goroutine when a worker slot is free and walked
+// inline otherwise, which bounds concurrency without risking a deadlock.
+func (s *walkState) visitEntries(dir, rel string, entries []os.DirEntry, depth int, ancestors []os.FileInfo, ignored ignoreRules) {
+	defer s.wg.Done()
+
+	s.stamp(dir, ancestors[len(ancestors)-1])
+
+	projectsOnly := s.walker.opts.ProjectsOnly
+	if projectsOnly {
+		// The root itself is never reported, only the projects below it
+		if rel != "" && s.walker.isProject(entries) {
+			s.record(dir)
+			return
+		}
+		// Directories one level past the depth limit are read only to look for markers
+		if s.maxDepth > 0 && depth > s.maxDepth {
+			return
+		}
+	}
+
+	if s.walker.opts.RespectIgnore {
+		ignored = ignored.with(s.loadIgnoreFiles(dir, rel, entries))
+	}
+
+	for _, entry := range entries {
+		if s.ctx.Err() != nil {
+			return
+		}
+		if slices.Contains(DefaultExcludes, entry.Name()) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		info, ok := directoryInfo(path, entry)
+		if !ok {
+			continue
+		}
+
+		childRel := entry.Name()
+		if rel != "" {
+			childRel = rel + "/" + entry.Name()
+		}
+		if s.walker.skip(entry.Name(), childRel, ignored) {
+			continue
+		}
+
+		// A directory that is its own ancestor means a symlink loop
+		if isAncestor(info, ancestors) {
+			continue
+		}
+
+		if !projectsOnly {
+			s.record(path)
+			if s.maxDepth > 0 && depth >= s.maxDepth {
+				continue
+			}
+		}
+
+		// Copy so that concurrent siblings never share a backing array
+		childAncestors := make([]os.FileInfo, len(ancestors), len(ancestors)+1)
+		copy(childAncestors, ancestors)
+		childAncestors = append(childAncestors, info)
+
+		s.wg.Add(1)
+		select {
+		case s.sem <- struct{}{}:
+			go func() {
+				defer func() { <-s.sem }()
+				s.visit(path, childRel, depth+1, childAncestors, ignored)
+			}()
+		default:
+			s.visit(path, childRel, depth+1, childAncestors, ignored)
+		}
+	}
+}
+
+// record adds path to the results, or passes it on when streaming
+func (s *walkState) record(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emit != nil {
+		s.emit(path)
+		return
+	}
+	s.results = append(s.results, path)
+}
+
+// isProject reports whether entries contain any of the project markers
+func (w *Walker) isProject(entries []os.DirEntry) bool {
+	for _, entry := range entries {
+		if slices.Contains(w.opts.ProjectMarkers, entry.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
+// skip reports whether the directory called name at rel must not be reported or descended into
+func (w *Walker) skip(name, rel string, ignored ignoreRules) bool {
+	if w.includes.matches(rel) {
+		return false
+	}
+	if w.opts.ExcludeHidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	return w.excludes.matches(rel) || ignored.matches(rel)
+}
+
+// stamp remembers the modification time of a directory or ignore file that was read
+func (s *walkState) stamp(path string, info os.FileInfo) {
+	s.mu.Lock()
+	s.stamps[path] = info.ModTime()
+	s.mu.Unlock()
+}
+
+// loadIgnoreFiles reads the ignore files present among entries of dir
+func (s *walkState) loadIgnoreFiles(dir, rel string, entries []os.DirEntry) ignoreRules {
+	var rules ignoreRules
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(ignoreFiles, entry.Name()) {
+			continue
+		}
+		filePath := filepath.Join(dir, entry.Name())
+		if info, err := os.Stat(filePath); err == nil {
+			s.stamp(filePath, info)
+		}
+		rules = append(rules, readIgnoreFile(filePath, rel)...)
+	}
+	return rules
+}
+
+// directoryInfo returns the file info for entry if it is a directory or a symlink
+// resolving to one
+func directoryInfo(path string, entry os.DirEntry) (os.FileInfo, bool) {
+	if entry.Type()&os.ModeSymlink != 0 {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			return nil, false
+		}
+		return info, true
+	}
+
+	if !entry.IsDir() {
+		return nil, false
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return nil, false
+	}
+	return info, true
+}
+
+// isAncestor reports whether info refers to the same directory as any of ancestors
+func isAncestor(info os.FileInfo, ancestors []os.FileInfo) bool {
+	for _, ancestor := range ancestors {
+		if os.SameFile(info, ancestor) {
+			return true
+		}
+	}
+	return false
+}
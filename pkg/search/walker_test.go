@@ -0,0 +1,356 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// createSearchFixture builds a directory tree exercising hidden directories,
+// excluded names, plain files, symlinked directories and a symlink loop
+func createSearchFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+
+	dirs := []string{
+		"a/b/c",
+		".hidden/x",
+		".git/objects",
+		"node_modules/pkg",
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("Failed to create test dir %s: %v", dir, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a", "file.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "a", "b", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+// searchBackends returns every backend available on this machine, so that the
+// shared suite asserts they all agree
+func searchBackends(t *testing.T, opts Options) map[string]func(string, int) ([]string, error) {
+	t.Helper()
+	ds := NewDirectorySearcher(opts)
+
+	backends := map[string]func(string, int) ([]string, error){
+		"walker": ds.walker.Walk,
+	}
+	if ds.canUseFd() {
+		backends["fd"] = ds.performFdSearch
+	} else {
+		t.Log("fd not installed, only the walker backend is exercised")
+	}
+	return backends
+}
+
+func TestSearchBackendsAgree(t *testing.T) {
+	root := createSearchFixture(t)
+
+	tests := []struct {
+		name     string
+		opts     Options
+		depth    int
+		expected []string
+	}{
+		{
+			name:     "Depth1",
+			depth:    1,
+			expected: []string{".hidden", "a", "link"},
+		},
+		{
+			name:     "Depth2",
+			depth:    2,
+			expected: []string{".hidden", ".hidden/x", "a", "a/b", "link", "link/b"},
+		},
+		{
+			name:  "Unlimited",
+			depth: 0,
+			expected: []string{
+				".hidden", ".hidden/x",
+				"a", "a/b", "a/b/c",
+				"link", "link/b", "link/b/c",
+			},
+		},
+		{
+			name:     "ExcludeHidden",
+			opts:     Options{ExcludeHidden: true},
+			depth:    0,
+			expected: []string{"a", "a/b", "a/b/c", "link", "link/b", "link/b/c"},
+		},
+		{
+			name:     "ExcludeName",
+			opts:     Options{Excludes: []string{"b"}},
+			depth:    0,
+			expected: []string{".hidden", ".hidden/x", "a", "link"},
+		},
+		{
+			name:     "ExcludeAnchoredPath",
+			opts:     Options{Excludes: []string{"/a/b"}},
+			depth:    0,
+			expected: []string{".hidden", ".hidden/x", "a", "link", "link/b", "link/b/c"},
+		},
+		{
+			name:     "ExcludeGlob",
+			opts:     Options{Excludes: []string{".h*", "l?nk"}},
+			depth:    0,
+			expected: []string{"a", "a/b", "a/b/c"},
+		},
+	}
+
+	for _, tt := range tests {
+		for name, search := range searchBackends(t, tt.opts) {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				results, err := search(root, tt.depth)
+				if err != nil {
+					t.Fatalf("search error = %v", err)
+				}
+
+				var expected []string
+				for _, rel := range tt.expected {
+					expected = append(expected, filepath.Join(root, rel))
+				}
+
+				if !reflect.DeepEqual(results, expected) {
+					t.Errorf("got %v, want %v", results, expected)
+				}
+			})
+		}
+	}
+}
+
+func TestSearchBackendsTrailingSlashRoot(t *testing.T) {
+	root := createSearchFixture(t)
+
+	for name, search := range searchBackends(t, Options{}) {
+		t.Run(name, func(t *testing.T) {
+			results, err := search(root+string(filepath.Separator), 1)
+			if err != nil {
+				t.Fatalf("search error = %v", err)
+			}
+			for _, r := range results {
+				if filepath.Dir(r) != root {
+					t.Errorf("expected %q to be a clean child of %q", r, root)
+				}
+			}
+		})
+	}
+}
+
+func TestWalkerWorkerCounts(t *testing.T) {
+	root := createSearchFixture(t)
+
+	expected, err := NewWalker(Options{Workers: 1}).Walk(root, 0)
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	for _, workers := range []int{0, 2, 64} {
+		results, err := NewWalker(Options{Workers: workers}).Walk(root, 0)
+		if err != nil {
+			t.Fatalf("Walk() with %d workers error = %v", workers, err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("Walk() with %d workers = %v, want %v", workers, results, expected)
+		}
+	}
+}
+
+func TestWalkerMissingRoot(t *testing.T) {
+	_, err := NewWalker(Options{}).Walk(filepath.Join(t.TempDir(), "missing"), 1)
+	if err == nil {
+		t.Error("Expected an error for a missing root")
+	}
+}
+
+func TestWalkerIncludes(t *testing.T) {
+	root := createSearchFixture(t)
+
+	opts := Options{
+		ExcludeHidden: true,
+		Excludes:      []string{"a"},
+		Includes:      []string{".hidden", "a", ".git"},
+	}
+	results, err := NewWalker(opts).Walk(root, 1)
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	// Includes override hidden and exclude rules, but never DefaultExcludes
+	expected := []string{filepath.Join(root, ".hidden"), filepath.Join(root, "a"), filepath.Join(root, "link")}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Walk() = %v, want %v", results, expected)
+	}
+}
+
+func TestWalkerRespectIgnore(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{"app/target/debug", "app/src", "app/dist", "vendor", "keep/vendor"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		".gitignore":     "# build output\n/vendor\n",
+		"app/.gitignore": "target/\ndist\n",
+		"app/.ignore":    "!dist\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		results, err := NewWalker(Options{RespectIgnore: true}).Walk(root, 0)
+		if err != nil {
+			t.Fatalf("Walk() error = %v", err)
+		}
+
+		// /vendor is anchored to the root, so keep/vendor survives; .ignore re-includes dist
+		var expected []string
+		for _, rel := range []string{"app", "app/dist", "app/src", "keep", "keep/vendor"} {
+			expected = append(expected, filepath.Join(root, rel))
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("Walk() = %v, want %v", results, expected)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		results, err := NewWalker(Options{}).Walk(root, 0)
+		if err != nil {
+			t.Fatalf("Walk() error = %v", err)
+		}
+		if len(results) != 8 {
+			t.Errorf("Expected 8 results with ignore files disregarded, got %d: %v", len(results), results)
+		}
+	})
+}
+
+func TestWalkerProjectsOnly(t *testing.T) {
+	root := t.TempDir()
+
+	dirs := []string{
+		"work/api/.git",
+		"work/api/internal/pkg",
+		"work/web/src",
+		"work/nested/deep/lib",
+		"notes/drafts",
+		"tools/cli",
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	markers := []string{
+		"work/web/package.json",
+		"work/nested/deep/lib/Cargo.toml",
+		"work/api/internal/pkg/go.mod",
+		"tools/cli/.tmx.toml",
+	}
+	for _, marker := range markers {
+		if err := os.WriteFile(filepath.Join(root, marker), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rels := func(rel ...string) []string {
+		var paths []string
+		for _, r := range rel {
+			paths = append(paths, filepath.Join(root, r))
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		depth    int
+		expected []string
+	}{
+		{
+			// The go.mod inside work/api is not reported: api is a project already
+			name:     "Unlimited",
+			opts:     Options{ProjectsOnly: true},
+			depth:    0,
+			expected: rels("tools/cli", "work/api", "work/nested/deep/lib", "work/web"),
+		},
+		{
+			name:     "DepthLimit",
+			opts:     Options{ProjectsOnly: true},
+			depth:    2,
+			expected: rels("tools/cli", "work/api", "work/web"),
+		},
+		{
+			name:     "CustomMarkers",
+			opts:     Options{ProjectsOnly: true, ProjectMarkers: []string{"drafts"}},
+			depth:    0,
+			expected: rels("notes"),
+		},
+		{
+			name:     "ExcludesApply",
+			opts:     Options{ProjectsOnly: true, Excludes: []string{"work"}},
+			depth:    0,
+			expected: rels("tools/cli"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := NewWalker(tt.opts).Walk(root, tt.depth)
+			if err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+			if !reflect.DeepEqual(results, tt.expected) {
+				t.Errorf("Walk() = %v, want %v", results, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWalkerStream(t *testing.T) {
+	root := createSearchFixture(t)
+	walker := NewWalker(Options{})
+
+	expected, err := walker.Walk(root, 0)
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	var streamed []string
+	if _, err := walker.WalkStream(context.Background(), root, 0, func(dir string) {
+		streamed = append(streamed, dir)
+	}); err != nil {
+		t.Fatalf("WalkStream() error = %v", err)
+	}
+
+	sort.Strings(streamed)
+	if !reflect.DeepEqual(streamed, expected) {
+		t.Errorf("WalkStream() = %v, want %v", streamed, expected)
+	}
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := walker.WalkStream(ctx, root, 0, func(string) {}); err == nil {
+			t.Error("Expected an error for a cancelled walk")
+		}
+	})
+}
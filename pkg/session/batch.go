@@ -0,0 +1,139 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Batch runs a sequence of tmux commands for the same server in a single tmux invocation. The commands are
+// chained with `;` and run by a control mode client (`tmux -C`), which wraps the output
+// of every command in a %begin ... %end or %error block, so a failure can be attributed
+// to the command that caused it. tmux stops at the first failing command.
+type Batch struct {
+	commands []*TmuxCommand
+}
+
+// NewBatch creates a Batch running commands in order
+func NewBatch(commands ...*TmuxCommand) *Batch {
+	return &Batch{commands: commands}
+}
+
+// Execute runs the commands of the batch, returning an error naming the first command
+// that failed, and the step it performs when it has one
+func (b *Batch) Execute() error {
+	if len(b.commands) == 0 {
+		return nil
+	}
+
+	cmd := exec.Command("tmux", b.args()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// A control client exits once its input ends, possibly before the output of the
+	// commands has been written, so input is kept open until all blocks have been read
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	blocks := readControlBlocks(stdout, len(b.commands))
+	stdin.Close()
+	io.Copy(io.Discard, stdout) //nolint:errcheck
+	runErr := cmd.Wait()
+
+	for i, block := range blocks {
+		if block.failed {
+			err := &TmuxError{
+				Command:  b.commands[i].String(),
+				ExitCode: cmd.ProcessState.ExitCode(),
+				Stderr:   strings.Join(block.output, "\n"),
+				err:      runErr,
+			}
+			if step := b.commands[i].step; step != "" {
+				return fmt.Errorf("%s: %w", step, err)
+			}
+			return err
+		}
+	}
+	if len(blocks) < len(b.commands) {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("tmux did not run all session commands: %s", msg)
+		}
+		if runErr != nil {
+			return fmt.Errorf("tmux did not run all session commands: %w", runErr)
+		}
+		return errors.New("tmux did not run all session commands")
+	}
+	return nil
+}
+
+// args returns the arguments of the tmux invocation running the batch, which is sent to
+// the server of its first command
+func (b *Batch) args() []string {
+	args := append(b.commands[0].server.args(), "-C")
+	for i, tc := range b.commands {
+		if i > 0 {
+			args = append(args, ";")
+		}
+		for _, arg := range tc.args {
+			args = append(args, escapeSeparator(arg))
+		}
+	}
+	return args
+}
+
+// escapeSeparator escapes a trailing `;`, which tmux would otherwise take as the end of
+// the command. tmux turns the escaped `\;` back into `;`.
+func escapeSeparator(arg string) string {
+	if before, ok := strings.CutSuffix(arg, ";"); ok {
+		return before + `\;`
+	}
+	return arg
+}
+
+// controlBlock is the output of one command in control mode
+type controlBlock struct {
+	output []string
+	failed bool // The block ended with %error, output holds the error message
+}
+
+// readControlBlocks reads the output of a control mode client running n commands and
+// returns their output blocks in the order the commands ran. Reading stops after n
+// blocks, after a failed one since tmux skips the remaining commands, or when the
+// output ends. Notifications outside blocks are ignored.
+func readControlBlocks(r io.Reader, n int) []controlBlock {
+	var blocks []controlBlock
+	var current *controlBlock
+
+	scanner := bufio.NewScanner(r)
+	for len(blocks) < n && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case current == nil:
+			if strings.HasPrefix(line, "%begin ") {
+				current = &controlBlock{}
+			}
+		case strings.HasPrefix(line, "%end "), strings.HasPrefix(line, "%error "):
+			current.failed = strings.HasPrefix(line, "%error ")
+			blocks = append(blocks, *current)
+			if current.failed {
+				return blocks
+			}
+			current = nil
+		default:
+			current.output = append(current.output, line)
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// isolateTmux starts the test with a private tmux server and returns it
+func isolateTmux(t *testing.T) Server {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	// An inherited $TMUX would make tmux talk to the current server
+	t.Setenv("TMUX", "")
+	os.Unsetenv("TMUX")
+
+	server := Server{Name: "tmx-test"}
+	t.Cleanup(func() { server.Command("kill-server").Execute() }) //nolint:errcheck
+	return server
+}
+
+func TestReadControlBlocks(t *testing.T) {
+	output := strings.Join([]string{
+		"%begin 1700000000 259 0",
+		"%end 1700000000 259 0",
+		"%window-add @1",
+		"%begin 1700000000 260 0",
+		"first line",
+		"second line",
+		"%end 1700000000 260 0",
+		"%begin 1700000000 261 0",
+		"can't find window: nope",
+		"%error 1700000000 261 0",
+		"%exit",
+	}, "\n")
+
+	want := []controlBlock{
+		{},
+		{output: []string{"first line", "second line"}},
+		{output: []string{"can't find window: nope"}, failed: true},
+	}
+	if got := readControlBlocks(strings.NewReader(output), 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("readControlBlocks() = %#v, want %#v", got, want)
+	}
+
+	// Reading stops once the expected blocks have been read
+	if got := readControlBlocks(strings.NewReader(output), 1); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("readControlBlocks(1) = %#v, want %#v", got, want[:1])
+	}
+}
+
+func TestBatchArgs(t *testing.T) {
+	batch := NewBatch(
+		Server{}.Command("new-session", "-ds", "s"),
+		Server{}.Command("send-keys", "-t", "s", "make; make install;", "Enter"),
+		Server{}.Command("send-keys", "-t", "s", `find . -exec true {} \;`, "Enter"),
+	)
+
+	want := []string{
+		"-C",
+		"new-session", "-ds", "s", ";",
+		"send-keys", "-t", "s", `make; make install\;`, "Enter", ";",
+		"send-keys", "-t", "s", `find . -exec true {} \\;`, "Enter",
+	}
+	if got := batch.args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+}
+
+func TestBatchExecute(t *testing.T) {
+	server := isolateTmux(t)
+
+	t.Run("RunsAllCommands", func(t *testing.T) {
+		err := NewBatch(
+			server.Command("new-session", "-ds", "batch", "-n", "one"),
+			server.Command("neww", "-t", "batch", "-n", "two;"),
+			server.Command("neww", "-t", "batch", "-n", "three"),
+		).Execute()
+		if err != nil {
+			t.Fatalf("Execute() error: %v", err)
+		}
+
+		output, err := server.Command("list-windows", "-t", "batch", "-F", "#{window_name}").Output()
+		if err != nil {
+			t.Fatalf("list-windows error: %v", err)
+		}
+		if got, want := strings.Fields(string(output)), []string{"one", "two;", "three"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("windows = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("ReportsFailingCommand", func(t *testing.T) {
+		err := NewBatch(
+			server.Command("new-session", "-ds", "failing"),
+			server.Command("send-keys", "-t", "failing:nope", "echo", "Enter").describe(`window "nope": run command`),
+			server.Command("neww", "-t", "failing", "-n", "skipped"),
+		).Execute()
+		if err == nil {
+			t.Fatal("Execute() succeeded, want an error")
+		}
+		if !strings.Contains(err.Error(), "send-keys -t failing:nope") || !strings.Contains(err.Error(), "can't find window") {
+			t.Errorf("Execute() error = %q, want it to name the send-keys command and its error", err)
+		}
+		if !strings.HasPrefix(err.Error(), `window "nope": run command: `) {
+			t.Errorf("Execute() error = %q, want it to start with the failing step", err)
+		}
+
+		// tmux stops at the failing command
+		output, _ := server.Command("list-windows", "-t", "failing", "-F", "#{window_name}").Output()
+		if strings.Contains(string(output), "skipped") {
+			t.Error("commands after the failing one were run")
+		}
+	})
+}
@@ -2,51 +2,75 @@ package session
 
 import (
 	"bytes"
-	"log"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
 )
 
 // tmuxCommand represents a command to be executed with tmux
 type TmuxCommand struct {
-	args []string
+	server Server
+	args   []string
+	nested bool   // Run with $TMUX unset, to attach from inside a session of another server
+	step   string // The step of building a session the command performs, for error messages
 }
 
-// New creates a new TmuxCommand with the given arguments
-func NewTmuxCommand(args ...string) *TmuxCommand {
-	return &TmuxCommand{args: args}
+// describe sets the session building step the command performs, named in its errors
+func (tc *TmuxCommand) describe(step string) *TmuxCommand {
+	tc.step = step
+	return tc
 }
 
-// Execute runs the tmux command and returns any error
+// command returns the tmux process running the command
+func (tc *TmuxCommand) command() *exec.Cmd {
+	cmd := exec.Command("tmux", append(tc.server.args(), tc.args...)...)
+	if tc.nested {
+		cmd.Env = slices.DeleteFunc(os.Environ(), func(v string) bool { return strings.HasPrefix(v, "TMUX=") })
+	}
+	return cmd
+}
+
+// Execute runs the tmux command and returns any error, a *TmuxError when tmux failed
 func (tc *TmuxCommand) Execute() error {
-	cmd := exec.Command("tmux", tc.args...)
-	return cmd.Run()
+	cmd := tc.command()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	return tc.run(cmd, &stderr)
 }
 
-// ExecuteWithIO runs the tmux command with standard IO connected
+// ExecuteWithIO runs the tmux command with standard input and output connected. What
+// tmux prints on standard error is returned in the *TmuxError when it fails.
 func (tc *TmuxCommand) ExecuteWithIO() error {
-	cmd := exec.Command("tmux", tc.args...)
+	cmd := tc.command()
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	return tc.run(cmd, &stderr)
 }
 
-// ExecuteVerbose runs the command and prints detailed output
-func (tc *TmuxCommand) ExecuteVerbose() error {
-	cmd := exec.Command("tmux", tc.args...)
+// ExecuteOutput runs the command and returns the output
+func (tc *TmuxCommand) Output() ([]byte, error) {
+	cmd := tc.command()
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	output, err := cmd.Output()
 	if err != nil {
-		log.Printf("Stderr: %s\n", stderr.String())
+		return output, newTmuxError(tc, err, stderr.String())
 	}
-	return err
+	return output, nil
 }
 
-// ExecuteOutput runs the command and returns the output
-func (tc *TmuxCommand) Output() ([]byte, error) {
-	cmd := exec.Command("tmux", tc.args...)
-	return cmd.Output()
+// run runs cmd, which writes its standard error to stderr
+func (tc *TmuxCommand) run(cmd *exec.Cmd, stderr *bytes.Buffer) error {
+	if err := cmd.Run(); err != nil {
+		return newTmuxError(tc, err, stderr.String())
+	}
+	return nil
+}
+
+// String returns the command line of the command, for error messages
+func (tc *TmuxCommand) String() string {
+	return "tmux " + strings.Join(append(tc.server.args(), tc.args...), " ")
 }
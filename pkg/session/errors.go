@@ -0,0 +1,74 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Errors reported by tmux that callers may want to handle, matched by TmuxError
+var (
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrNoServer         = errors.New("no tmux server running")
+	ErrDuplicateSession = errors.New("duplicate session")
+	ErrNestedSession    = errors.New("already inside tmux")
+)
+
+// tmuxMessages maps messages printed by tmux to the errors they stand for
+var tmuxMessages = []struct {
+	text string
+	err  error
+}{
+	{"can't find session", ErrSessionNotFound},
+	{"session not found", ErrSessionNotFound},
+	{"no server running", ErrNoServer},
+	// Printed when the socket of a -L or -S server is missing or stale
+	{"(No such file or directory)", ErrNoServer},
+	{"(Connection refused)", ErrNoServer},
+	// attach-session starts a server, which exits right away when it has no sessions
+	{"no sessions", ErrNoServer},
+	{"duplicate session", ErrDuplicateSession},
+	{"sessions should be nested with care", ErrNestedSession},
+}
+
+// TmuxError is a failed tmux command. It matches ErrSessionNotFound, ErrNoServer,
+// ErrDuplicateSession or ErrNestedSession with errors.Is when tmux reported one of them.
+type TmuxError struct {
+	Command  string // Command line of the failed command
+	ExitCode int    // Exit code of tmux, -1 when it did not exit normally
+	Stderr   string // What tmux printed about the failure
+	err      error  // The underlying error when tmux printed nothing
+}
+
+// newTmuxError returns the TmuxError for tc failing with err after printing stderr, or
+// err itself when tmux could not be run at all
+func newTmuxError(tc *TmuxCommand, err error, stderr string) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	return &TmuxError{
+		Command:  tc.String(),
+		ExitCode: exitErr.ExitCode(),
+		Stderr:   strings.TrimSpace(stderr),
+		err:      err,
+	}
+}
+
+func (e *TmuxError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s: %v", e.Command, e.err)
+	}
+	return fmt.Sprintf("%s: %s", e.Command, e.Stderr)
+}
+
+// Unwrap returns the error tmux reported, when it is one of the known ones
+func (e *TmuxError) Unwrap() error {
+	for _, msg := range tmuxMessages {
+		if strings.Contains(e.Stderr, msg.text) {
+			return msg.err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package session
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestTmuxErrorIs(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   error
+	}{
+		{"can't find session: api", ErrSessionNotFound},
+		{"no server running on /tmp/tmux-1000/default", ErrNoServer},
+		{"error connecting to /tmp/tmux-1000/work (No such file or directory)", ErrNoServer},
+		{"error connecting to /tmp/tmux-1000/work (Connection refused)", ErrNoServer},
+		{"no sessions", ErrNoServer},
+		{"duplicate session: api", ErrDuplicateSession},
+		{"sessions should be nested with care, unset $TMUX to force", ErrNestedSession},
+		{"error connecting to /tmp/tmux-1000/work (Permission denied)", nil},
+		{"unknown command: bogus", nil},
+	}
+
+	sentinels := []error{ErrSessionNotFound, ErrNoServer, ErrDuplicateSession, ErrNestedSession}
+	for _, tt := range tests {
+		err := &TmuxError{Command: "tmux has-session -t api", ExitCode: 1, Stderr: tt.stderr}
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("errors.Is(%q, %v) = %v", tt.stderr, sentinel, got)
+			}
+		}
+	}
+}
+
+func TestTmuxErrorMessage(t *testing.T) {
+	err := &TmuxError{Command: "tmux kill-session -t api", ExitCode: 1, Stderr: "can't find session: api"}
+	if want := "tmux kill-session -t api: can't find session: api"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	server := isolateTmux(t)
+
+	err := server.Command("list-sessions").Execute()
+	if !errors.Is(err, ErrNoServer) {
+		t.Errorf("list-sessions without a server: error = %v, want ErrNoServer", err)
+	}
+
+	if err := server.Command("new-session", "-ds", "api").Execute(); err != nil {
+		t.Fatalf("new-session error: %v", err)
+	}
+
+	err = server.Command("new-session", "-ds", "api").Execute()
+	if !errors.Is(err, ErrDuplicateSession) {
+		t.Errorf("new-session for an existing session: error = %v, want ErrDuplicateSession", err)
+	}
+
+	_, err = server.Command("list-windows", "-t", "missing").Output()
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("list-windows for a missing session: error = %v, want ErrSessionNotFound", err)
+	}
+	var tmuxErr *TmuxError
+	if !errors.As(err, &tmuxErr) || tmuxErr.ExitCode != 1 || tmuxErr.Command != "tmux -L tmx-test list-windows -t missing" {
+		t.Errorf("error = %#v, want a TmuxError with the command and exit code 1", err)
+	}
+}
+
+func TestExecuteWithoutTmux(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := Server{}.Command("list-sessions").Execute()
+	var tmuxErr *TmuxError
+	if errors.As(err, &tmuxErr) || !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("Execute() without tmux error = %v, want exec.ErrNotFound", err)
+	}
+}
@@ -1,54 +1,141 @@
 package session
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/frecency"
+	"github.com/vbrdnk/tmx/pkg/git"
 	"github.com/vbrdnk/tmx/pkg/history"
 )
 
 // SessionManager handles tmux session lifecycle operations
 type SessionManager struct {
-	config *config.Config
+	config      *config.Config
+	server      Server          // tmux server of sessions whose workspace sets no socket
+	keepOnError bool            // Keep sessions whose creation failed half-way, for inspection
+	frecency    frecency.Source // nil when opened directories are not recorded
+}
+
+// Target identifies a session on a tmux server
+type Target struct {
+	Server  Server `json:"server"`
+	Session string `json:"session"`
 }
 
 // NewSessionManager creates a new SessionManager instance
 func NewSessionManager(cfg *config.Config) *SessionManager {
-	return &SessionManager{
+	sm := &SessionManager{
 		config: cfg,
 	}
+
+	if cfg != nil && cfg.GetUseZoxide() && cfg.GetFrecencyRecord() {
+		if source, err := frecency.New(cfg.GetFrecencySource()); err == nil {
+			sm.frecency = source
+		}
+	}
+
+	return sm
+}
+
+// SetServer sends the commands of the manager to server instead of the default tmux
+// server. Sessions of workspaces setting `socket` stay on their own server.
+func (sm *SessionManager) SetServer(server Server) {
+	sm.server = server
 }
 
-// ResolveSession creates a new session if it doesn't exist and then attaches to it
+// SetKeepOnError keeps a session whose creation failed after tmux started it, instead
+// of killing the half-built session
+func (sm *SessionManager) SetKeepOnError(keep bool) {
+	sm.keepOnError = keep
+}
+
+// Server returns the tmux server the manager sends commands to
+func (sm *SessionManager) Server() Server {
+	return sm.server
+}
+
+// ResolveSession creates a new session if it doesn't exist and then attaches to it.
+// With worktree_mode enabled, sessions for git repositories are named after the
+// repository and the branch checked out in dir.
 func (sm *SessionManager) ResolveSession(dir string) error {
-	// Determine session name
-	sessionName := sm.determineSessionName(dir)
+	return sm.resolve(dir, false)
+}
+
+// ResolveWorktreeSession behaves like ResolveSession but always names the session
+// after the repository and branch of dir, regardless of worktree_mode
+func (sm *SessionManager) ResolveWorktreeSession(dir string) error {
+	return sm.resolve(dir, true)
+}
+
+// resolve creates the session for dir if needed, attaches to it and records dir with
+// the frecency source
+func (sm *SessionManager) resolve(dir string, worktreeMode bool) error {
+	target, err := sm.EnsureSession(dir, worktreeMode)
+	if err != nil {
+		return err
+	}
+	if err := sm.Attach(target); err != nil {
+		return err
+	}
+
+	sm.recordVisit(dir)
+	return nil
+}
+
+// EnsureSession creates the session for dir if it doesn't exist and returns it, without
+// attaching to it. With worktree set, the session is named after the repository and
+// branch of dir, as ResolveWorktreeSession does; otherwise worktree_mode applies.
+func (sm *SessionManager) EnsureSession(dir string, worktree bool) (Target, error) {
+	var repo *git.Info
+	if worktree || (sm.config != nil && sm.config.GetWorktreeMode()) {
+		// Directories outside a repository keep their regular session name
+		repo, _ = git.Inspect(dir)
+	}
+
+	// Determine session name and the server it lives on
+	target := Target{
+		Server:  sm.workspaceServer(sm.findWorkspace(dir, repo)),
+		Session: sm.determineSessionName(dir, repo),
+	}
 
 	// Check if session exists, create if it doesn't
-	if !sm.sessionExists(sessionName) {
-		if err := sm.createSession(sessionName, dir); err != nil {
-			return err
+	if !sm.sessionExists(target) {
+		if err := sm.createSession(target, dir, repo); err != nil {
+			return Target{}, err
 		}
 	}
 
-	return sm.AttachToSession(sessionName)
+	return target, nil
 }
 
 // AttachToSession attaches to an existing tmux session. When called from outside tmux,
 // detaches any other clients before attaching.
 func (sm *SessionManager) AttachToSession(sessionName string) error {
+	return sm.Attach(sm.sessionTarget(sessionName))
+}
+
+// Attach attaches to the session of target, switching the current client when running
+// inside a session of the same server. From inside a session of another server, the
+// session is attached nested in the current one.
+func (sm *SessionManager) Attach(target Target) error {
+	sessionName := target.Session
 	var tc *TmuxCommand
-	tmuxRunning := TmuxRunning()
 
-	if !tmuxRunning {
+	if !target.Server.Inside() {
 		// -d detaches all other clients from all sessions before attaching
-		tc = NewTmuxCommand("attach-session", "-d", "-t", sessionName)
+		tc = target.Server.Command("attach-session", "-d", "-t", sessionName)
+		tc.nested = TmuxRunning()
 	} else {
-		tc = NewTmuxCommand("switch-client", "-t", sessionName)
+		tc = target.Server.Command("switch-client", "-t", sessionName)
 	}
 
 	if err := tc.ExecuteWithIO(); err != nil {
@@ -64,99 +151,401 @@ func (sm *SessionManager) AttachToSession(sessionName string) error {
 	return nil
 }
 
+// recordVisit tells the frecency source about dir so that directories opened through
+// tmx gain frecency. Errors are silently ignored, like history failures.
+func (sm *SessionManager) recordVisit(dir string) {
+	if sm.frecency == nil {
+		return
+	}
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	sm.frecency.Add(dir) //nolint:errcheck
+}
+
 // KillSession terminates a tmux session
 func (sm *SessionManager) KillSession(sessionName string) error {
-	return NewTmuxCommand("kill-session", "-t", sessionName).ExecuteWithIO()
+	target := sm.sessionTarget(sessionName)
+	return target.Server.Command("kill-session", "-t", sessionName).ExecuteWithIO()
+}
+
+// RenameSession renames the session called oldName to newName, made a valid session
+// name the way directory names are, and updates the history so that the session stays
+// among the recent ones. It returns the new name of the session.
+func (sm *SessionManager) RenameSession(oldName, newName string) (string, error) {
+	newName = sm.createSessionName(strings.TrimSpace(newName))
+	if newName == "" {
+		return "", errors.New("the new session name is empty")
+	}
+	if newName == oldName {
+		return "", fmt.Errorf("session %s is already called %s", oldName, newName)
+	}
+
+	// "=" makes tmux match the exact name instead of a prefix
+	target := sm.sessionTarget(oldName)
+	if target.Server.Command("has-session", "-t", "="+newName).Execute() == nil {
+		return "", fmt.Errorf("cannot rename %s: %w: %s", oldName, ErrDuplicateSession, newName)
+	}
+	if err := target.Server.Command("rename-session", "-t", "="+oldName, newName).Execute(); err != nil {
+		return "", err
+	}
+
+	if err := history.Rename(oldName, newName); err != nil {
+		color.Yellow("Failed to update the session history: %v", err)
+	}
+	return newName, nil
+}
+
+// SessionWorkspace returns the name of the workspace whose sessions are called
+// sessionName, or an empty string when there is none
+func (sm *SessionManager) SessionWorkspace(sessionName string) string {
+	if ws := sm.namedWorkspace(sessionName); ws != nil {
+		return ws.Name
+	}
+	return ""
+}
+
+// namedWorkspace returns the workspace whose sessions are called sessionName, or nil
+func (sm *SessionManager) namedWorkspace(sessionName string) *config.WorkspaceConfig {
+	if sm.config == nil {
+		return nil
+	}
+	for i, ws := range sm.config.Workspace {
+		name := ws.Name
+		if strings.Contains(name, "{{") {
+			// Rendered as for a session opened in the workspace directory itself
+			name = interpolate(name, workspaceVars(ws.Directory, nil))
+		}
+		if sm.createSessionName(name) == sessionName {
+			return &sm.config.Workspace[i]
+		}
+	}
+	return nil
 }
 
 // ListSessions lists all active tmux sessions
 func (sm *SessionManager) ListSessions() error {
-	return NewTmuxCommand("list-sessions").ExecuteWithIO()
+	return sm.server.Command("list-sessions").ExecuteWithIO()
+}
+
+// Sessions returns the active tmux sessions as printed by `tmux list-sessions`, one per line
+func (sm *SessionManager) Sessions() ([]string, error) {
+	output, err := sm.server.Command("list-sessions").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(output), "\n"), "\n"), nil
 }
 
 // sessionExists checks if a tmux session exists
-func (sm *SessionManager) sessionExists(sessionName string) bool {
-	tc := NewTmuxCommand("has-session", "-t", sessionName)
+func (sm *SessionManager) sessionExists(target Target) bool {
+	// = matches the name exactly; tmux would otherwise accept any session it is a prefix of
+	tc := target.Server.Command("has-session", "-t", "="+target.Session)
 	return tc.Execute() == nil
 }
 
-// createSession creates a new tmux session with the given name in the specified directory
-func (sm *SessionManager) createSession(sessionName string, dir string) error {
+// sessionTarget returns the session called sessionName: on the server of the workspace
+// with that name when it sets `socket`, on the manager's server otherwise
+func (sm *SessionManager) sessionTarget(sessionName string) Target {
+	return Target{Server: sm.workspaceServer(sm.namedWorkspace(sessionName)), Session: sessionName}
+}
+
+// workspaceServer returns the tmux server of sessions for ws, which may be nil
+func (sm *SessionManager) workspaceServer(ws *config.WorkspaceConfig) Server {
+	if ws == nil || ws.Socket == "" {
+		return sm.server
+	}
+	return ServerForSocket(ws.Socket)
+}
+
+// createSession creates the session of target in the specified directory
+func (sm *SessionManager) createSession(target Target, dir string, repo *git.Info) error {
+	sessionName := target.Session
 	color.Green(fmt.Sprintf("Creating new session: %s in directory: %s\n", sessionName, dir))
 
 	var commands []*TmuxCommand
+	var ws *config.WorkspaceConfig
 
 	// Handle case with no config
 	if sm.config == nil {
 		color.Green("Using default configuration (no config file found)\n")
-		commands = append(commands, NewTmuxCommand("new-session", "-ds", sessionName, "-c", dir))
+		commands = append(commands, target.Server.Command("new-session", "-ds", sessionName, "-c", dir).describe("create session"))
 	} else {
-		commands = sm.buildSessionCommands(sessionName, dir)
+		ws = sm.sessionWorkspace(sessionName, dir, repo)
+		commands = sm.sessionCommands(target.Server, sessionName, dir, ws)
 	}
 
 	if len(commands) == 0 {
 		return fmt.Errorf("no commands generated for session creation")
 	}
 
-	for _, cmd := range commands {
-		if err := cmd.ExecuteVerbose(); err != nil {
-			return fmt.Errorf("failed to execute session command: %w", err)
-		}
+	// The whole session is built by a single tmux process
+	if err := NewBatch(commands...).Execute(); err != nil {
+		sm.rollback(target, err)
+		return fmt.Errorf("failed to create session %s: %w", sessionName, err)
+	}
+
+	if ws != nil {
+		runHooks(ws.Hooks.OnCreate, dir, ws.Env)
 	}
 
 	color.Green(fmt.Sprintf("Successfully started tmux session: %s\n", sessionName))
 	return nil
 }
 
-// buildSessionCommands generates commands for creating a session based on config
-func (sm *SessionManager) buildSessionCommands(sessionName string, dir string) []*TmuxCommand {
+// rollback kills the session of target after its creation failed with err, so that the
+// next run creates it again instead of attaching to a half-built session
+func (sm *SessionManager) rollback(target Target, err error) {
+	// tmux did not start the session, or another one with its name already existed
+	if errors.Is(err, ErrDuplicateSession) || !sm.sessionExists(target) {
+		return
+	}
+	if sm.keepOnError {
+		color.Yellow("Keeping the partially created session %s", target.Session)
+		return
+	}
+	if killErr := target.Server.Command("kill-session", "-t", "="+target.Session).Execute(); killErr != nil {
+		color.Yellow("Failed to remove the partially created session %s: %v", target.Session, killErr)
+		return
+	}
+	color.Yellow("Removed the partially created session %s; use --keep-on-error to inspect it", target.Session)
+}
+
+// sessionCommands generates the commands creating a session on server for the rendered
+// workspace ws, or a default session when ws is nil
+func (sm *SessionManager) sessionCommands(server Server, sessionName string, dir string, ws *config.WorkspaceConfig) []*TmuxCommand {
 	var commands []*TmuxCommand
 
-	// Try to find a matching workspace
-	for _, ws := range sm.config.Workspace {
-		if filepath.Base(dir) == filepath.Base(ws.Directory) {
-			sessionName = sm.createSessionName(ws.Name)
-
-			// Create first window with new-session
-			firstWindow := true
-			for _, window := range ws.Windows {
-				if firstWindow {
-					commands = append(commands, NewTmuxCommand("new-session", "-ds", sessionName, "-c", dir, "-n", window.Name))
-					firstWindow = false
-				} else {
-					commands = append(commands, NewTmuxCommand("neww", "-t", sessionName, "-c", dir, "-n", window.Name))
-				}
-				if window.Command != "" {
-					// Wait for the shell to be ready before sending keys
-					commands = append(commands, NewTmuxCommand("run-shell", "sleep 0.1"))
-					commands = append(commands, NewTmuxCommand("send-keys", "-t", sessionName+":"+window.Name, window.Command, "Enter"))
+	if ws != nil {
+		envArgs := envFlags(ws.Env)
+		// Window names may contain `.` or `:`, which tmux takes for pane and window separators
+		// in targets, so windows are addressed relative to the session's current window. Each
+		// window becomes the current one when it is created.
+		session := "=" + sessionName
+		current := session + ":"
+
+		// A workspace may only set env or hooks, in which case it gets a single default window
+		if len(ws.Windows) == 0 {
+			return []*TmuxCommand{server.Command(append([]string{"new-session", "-ds", sessionName, "-c", dir}, envArgs...)...).describe("create session")}
+		}
+
+		// Create first window with new-session
+		firstWindow := true
+		for _, window := range ws.Windows {
+			cwd := windowDir(dir, window.Dir)
+			step := fmt.Sprintf("window %q", window.Name)
+			if firstWindow {
+				args := append([]string{"new-session", "-ds", sessionName, "-c", dir, "-n", window.Name}, envArgs...)
+				commands = append(commands, server.Command(args...).describe("create session"))
+				if len(window.Env) > 0 || cwd != dir {
+					// new-session -c and -e also apply to the whole session, so the first window's
+					// own directory and variables are applied by restarting its freshly started shell
+					args := append([]string{"respawn-pane", "-k", "-t", current, "-c", cwd}, envFlags(window.Env)...)
+					commands = append(commands, server.Command(args...).describe(step+": set directory and env"))
 				}
+				firstWindow = false
+			} else {
+				args := append([]string{"neww", "-t", session, "-c", cwd, "-n", window.Name}, envFlags(window.Env)...)
+				commands = append(commands, server.Command(args...).describe(step+": create"))
+			}
+			if window.Command != "" {
+				// Wait for the shell to be ready before sending keys, with tmux's own timer
+				// rather than a sleep process (run-shell -d without a command needs tmux 3.2)
+				commands = append(commands, server.Command("run-shell", "-d", "0.1").describe(step+": wait for the shell"))
+				commands = append(commands, server.Command("send-keys", "-t", current, window.Command, "Enter").describe(step+": run command"))
 			}
-			return commands
 		}
+
+		// tmux selects the last window created unless another one asks for the focus, which
+		// is found by its offset from the last window
+		if i := slices.IndexFunc(ws.Windows, func(w config.WindowConfig) bool { return w.Focus }); i >= 0 && i < len(ws.Windows)-1 {
+			step := fmt.Sprintf("window %q: focus", ws.Windows[i].Name)
+			offset := fmt.Sprintf("%s-%d", current, len(ws.Windows)-1-i)
+			commands = append(commands, server.Command("select-window", "-t", offset).describe(step))
+		}
+		return commands
 	}
 
 	// No matching workspace found, create a default session
 	color.Yellow("No matching workspace found. Creating default session...\n")
-	return []*TmuxCommand{NewTmuxCommand("new-session", "-ds", sessionName, "-c", dir)}
+	return []*TmuxCommand{server.Command("new-session", "-ds", sessionName, "-c", dir).describe("create session")}
+}
+
+// windowDir resolves a window's configured directory against the session directory
+func windowDir(sessionDir, dir string) string {
+	if dir == "" {
+		return sessionDir
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(sessionDir, dir)
 }
 
-// determineSessionName tries to find a matching workspace in config or falls back to dir basename
-func (sm *SessionManager) determineSessionName(dir string) string {
-	// If no config, use directory name
+// envFlags converts env into `-e NAME=value` flags for new-session and new-window, sorted by name
+func envFlags(env map[string]string) []string {
+	var flags []string
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		flags = append(flags, "-e", name+"="+env[name])
+	}
+	return flags
+}
+
+// runHooks runs the shell commands in hooks one after another in dir with env added to
+// the environment. A failing hook is reported but does not undo the session.
+func runHooks(hooks []string, dir string, env map[string]string) {
+	for _, hook := range hooks {
+		cmd := exec.Command("sh", "-c", hook)
+		cmd.Dir = dir
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = os.Environ()
+		for _, name := range slices.Sorted(maps.Keys(env)) {
+			cmd.Env = append(cmd.Env, name+"="+env[name])
+		}
+
+		if err := cmd.Run(); err != nil {
+			color.Yellow("Hook %q failed: %v", hook, err)
+		}
+	}
+}
+
+// findWorkspace returns the workspace configured for dir, or nil. When repo is set,
+// a workspace for the repository also matches any of its worktrees.
+func (sm *SessionManager) findWorkspace(dir string, repo *git.Info) *config.WorkspaceConfig {
 	if sm.config == nil {
-		return sm.createSessionName(filepath.Base(dir))
+		return nil
 	}
 
-	// Try to find a matching workspace
-	for _, ws := range sm.config.Workspace {
+	for i, ws := range sm.config.Workspace {
 		if filepath.Base(dir) == filepath.Base(ws.Directory) {
-			return sm.createSessionName(ws.Name)
+			return &sm.config.Workspace[i]
+		}
+	}
+
+	if repo != nil {
+		for i, ws := range sm.config.Workspace {
+			if filepath.Base(ws.Directory) == repo.Repo {
+				return &sm.config.Workspace[i]
+			}
 		}
 	}
 
-	// Default to directory name if no match found
-	return sm.createSessionName(filepath.Base(dir))
+	return nil
+}
+
+// sessionWorkspace returns the workspace configured for dir rendered for the session
+// called sessionName, or nil
+func (sm *SessionManager) sessionWorkspace(sessionName, dir string, repo *git.Info) *config.WorkspaceConfig {
+	ws := sm.findWorkspace(dir, repo)
+	if ws == nil {
+		return nil
+	}
+	vars := workspaceVars(dir, repo)
+	vars.Session = sessionName
+	ws = renderWorkspace(ws, vars)
+
+	if ws.EnvFile != "" {
+		ws.Env = withEnvFile(ws.Env, ws.EnvFile, dir)
+	}
+	return ws
+}
+
+// withEnvFile returns env with the variables of envFile added. Variables set in env take
+// precedence; a file that cannot be read is reported and skipped.
+func withEnvFile(env map[string]string, envFile, dir string) map[string]string {
+	if !filepath.IsAbs(envFile) {
+		envFile = filepath.Join(dir, envFile)
+	}
+
+	fileEnv, err := config.ReadEnvFile(envFile)
+	if err != nil {
+		color.Yellow("Skipping env file: %v", err)
+		return env
+	}
+
+	maps.Copy(fileEnv, env)
+	return fileEnv
+}
+
+// workspaceVars returns the tmx variables for a session in dir, without the workspace
+// and session names which are only known later
+func workspaceVars(dir string, repo *git.Info) config.Vars {
+	vars := config.Vars{Dir: dir}
+	if absDir, err := filepath.Abs(dir); err == nil {
+		vars.Dir = absDir
+	}
+
+	if repo == nil {
+		// Outside worktree mode the repository has not been inspected yet
+		repo, _ = git.Inspect(dir)
+	}
+	if repo != nil {
+		vars.GitBranch = repo.Branch
+	}
+	return vars
+}
+
+// renderWorkspace returns a copy of ws with the {{.Var}} placeholders in its name,
+// window commands, hooks and env replaced
+func renderWorkspace(ws *config.WorkspaceConfig, vars config.Vars) *config.WorkspaceConfig {
+	rendered := *ws
+	rendered.Name = interpolate(ws.Name, vars)
+	vars.Name = rendered.Name
+
+	rendered.Windows = make([]config.WindowConfig, len(ws.Windows))
+	for i, window := range ws.Windows {
+		window.Command = interpolate(window.Command, vars)
+		window.Dir = interpolate(window.Dir, vars)
+		window.Env = renderEnv(window.Env, vars)
+		rendered.Windows[i] = window
+	}
+
+	rendered.Hooks.OnCreate = make([]string, len(ws.Hooks.OnCreate))
+	for i, hook := range ws.Hooks.OnCreate {
+		rendered.Hooks.OnCreate[i] = interpolate(hook, vars)
+	}
+
+	rendered.Env = renderEnv(ws.Env, vars)
+	return &rendered
+}
+
+// renderEnv returns a copy of env with the placeholders in its values replaced
+func renderEnv(env map[string]string, vars config.Vars) map[string]string {
+	if env == nil {
+		return nil
+	}
+	rendered := make(map[string]string, len(env))
+	for name, value := range env {
+		rendered[name] = interpolate(value, vars)
+	}
+	return rendered
+}
+
+// interpolate renders the placeholders in s. They are validated when the config is
+// loaded, so a value that still fails to render is used as is.
+func interpolate(s string, vars config.Vars) string {
+	if rendered, err := config.Interpolate(s, vars); err == nil {
+		return rendered
+	}
+	return s
+}
+
+// determineSessionName tries to find a matching workspace in config or falls back to dir basename.
+// When repo is set, the session is named "<repo>/<branch>" after the workspace or repository.
+func (sm *SessionManager) determineSessionName(dir string, repo *git.Info) string {
+	name := filepath.Base(dir)
+	if repo != nil {
+		name = repo.Repo
+	}
+	if ws := sm.findWorkspace(dir, repo); ws != nil {
+		name = interpolate(ws.Name, workspaceVars(dir, repo))
+	}
+
+	if repo != nil && repo.Branch != "" {
+		return sm.createSessionName(name) + "/" + sm.createSessionName(repo.Branch)
+	}
+	return sm.createSessionName(name)
 }
 
 // createSessionName creates a valid tmux session name from a directory name
@@ -1,9 +1,18 @@
 package session
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/vbrdnk/tmx/pkg/config"
+	"github.com/vbrdnk/tmx/pkg/frecency"
+	"github.com/vbrdnk/tmx/pkg/git"
+	"github.com/vbrdnk/tmx/pkg/history"
 )
 
 func TestNewSessionManager(t *testing.T) {
@@ -87,7 +96,7 @@ func TestCreateSessionName(t *testing.T) {
 func TestDetermineSessionName(t *testing.T) {
 	t.Run("WithNilConfig", func(t *testing.T) {
 		sm := NewSessionManager(nil)
-		result := sm.determineSessionName("/path/to/myproject")
+		result := sm.determineSessionName("/path/to/myproject", nil)
 
 		// Should use directory basename
 		expected := "myproject"
@@ -107,7 +116,7 @@ func TestDetermineSessionName(t *testing.T) {
 			},
 		}
 		sm := NewSessionManager(cfg)
-		result := sm.determineSessionName("/path/to/myproject")
+		result := sm.determineSessionName("/path/to/myproject", nil)
 
 		// Should use workspace name (sanitized)
 		expected := "Custom_Project_Name"
@@ -127,7 +136,7 @@ func TestDetermineSessionName(t *testing.T) {
 			},
 		}
 		sm := NewSessionManager(cfg)
-		result := sm.determineSessionName("/path/to/myproject")
+		result := sm.determineSessionName("/path/to/myproject", nil)
 
 		// Should fall back to directory basename
 		expected := "myproject"
@@ -138,7 +147,7 @@ func TestDetermineSessionName(t *testing.T) {
 
 	t.Run("WithDotInDirectoryName", func(t *testing.T) {
 		sm := NewSessionManager(nil)
-		result := sm.determineSessionName("/path/to/my.project")
+		result := sm.determineSessionName("/path/to/my.project", nil)
 
 		// Should sanitize dots
 		expected := "my_project"
@@ -148,14 +157,70 @@ func TestDetermineSessionName(t *testing.T) {
 	})
 }
 
-func TestBuildSessionCommands(t *testing.T) {
+func TestDetermineSessionNameWorktree(t *testing.T) {
+	repo := &git.Info{Repo: "tmx", Root: "/src/tmx-feature-login", Branch: "feature/login", Worktree: true}
+
+	t.Run("WithoutWorkspace", func(t *testing.T) {
+		sm := NewSessionManager(nil)
+		result := sm.determineSessionName("/src/tmx-feature-login", repo)
+
+		// Should use the repository and sanitized branch name
+		expected := "tmx/feature_login"
+		if result != expected {
+			t.Errorf("determineSessionName() = %q, want %q", result, expected)
+		}
+	})
+
+	t.Run("WithRepositoryWorkspace", func(t *testing.T) {
+		cfg := &config.Config{
+			Workspace: []config.WorkspaceConfig{
+				{
+					Directory: "/src/tmx",
+					Name:      "Tmux Sessionizer",
+					Windows:   []config.WindowConfig{{Name: "editor"}, {Name: "shell"}},
+				},
+			},
+		}
+		sm := NewSessionManager(cfg)
+
+		// The workspace of the main worktree applies to every worktree of the repository
+		result := sm.determineSessionName("/src/tmx-feature-login", repo)
+		expected := "Tmux_Sessionizer/feature_login"
+		if result != expected {
+			t.Errorf("determineSessionName() = %q, want %q", result, expected)
+		}
+
+		ws := sm.sessionWorkspace(result, "/src/tmx-feature-login", repo)
+		commands := sm.sessionCommands(Server{}, result, "/src/tmx-feature-login", ws)
+		if len(commands) != 2 {
+			t.Fatalf("Expected 2 commands, got %d", len(commands))
+		}
+		if commands[0].args[2] != expected {
+			t.Errorf("Expected session %q to be created, got %q", expected, commands[0].args[2])
+		}
+	})
+
+	t.Run("WithoutBranch", func(t *testing.T) {
+		sm := NewSessionManager(nil)
+		result := sm.determineSessionName("/src/tmx", &git.Info{Repo: "tmx", Root: "/src/tmx"})
+
+		// A repository without commits has no branch to name the session after
+		expected := "tmx"
+		if result != expected {
+			t.Errorf("determineSessionName() = %q, want %q", result, expected)
+		}
+	})
+}
+
+func TestSessionCommands(t *testing.T) {
 	t.Run("WithoutMatchingWorkspace", func(t *testing.T) {
 		cfg := &config.Config{
 			Workspace: []config.WorkspaceConfig{},
 		}
 		sm := NewSessionManager(cfg)
 
-		commands := sm.buildSessionCommands("testsession", "/path/to/project")
+		ws := sm.sessionWorkspace("testsession", "/path/to/project", nil)
+		commands := sm.sessionCommands(Server{}, "testsession", "/path/to/project", ws)
 
 		// Should create a single default session command
 		if len(commands) != 1 {
@@ -182,7 +247,8 @@ func TestBuildSessionCommands(t *testing.T) {
 		}
 		sm := NewSessionManager(cfg)
 
-		commands := sm.buildSessionCommands("testsession", "/path/to/project")
+		ws := sm.sessionWorkspace("testsession", "/path/to/project", nil)
+		commands := sm.sessionCommands(Server{}, "testsession", "/path/to/project", ws)
 
 		// Should create commands for each window (3 windows = 1 new-session + 2 neww)
 		if len(commands) != 3 {
@@ -221,9 +287,10 @@ func TestBuildSessionCommands(t *testing.T) {
 		}
 		sm := NewSessionManager(cfg)
 
-		commands := sm.buildSessionCommands("testsession", "/path/to/project")
+		ws := sm.sessionWorkspace("testsession", "/path/to/project", nil)
+		commands := sm.sessionCommands(Server{}, "testsession", "/path/to/project", ws)
 
-		// 2 windows + 1 run-shell (sleep) + 1 send-keys for the git window = 4 commands
+		// 2 windows + 1 run-shell (delay) + 1 send-keys for the git window = 4 commands
 		if len(commands) != 4 {
 			t.Errorf("Expected 4 commands, got %d", len(commands))
 		}
@@ -236,6 +303,164 @@ func TestBuildSessionCommands(t *testing.T) {
 	})
 }
 
+func TestSessionCommandsEnv(t *testing.T) {
+	cfg := &config.Config{
+		Workspace: []config.WorkspaceConfig{
+			{
+				Directory: "/path/to/project",
+				Name:      "project",
+				Env:       map[string]string{"B": "2", "A": "1"},
+			},
+		},
+	}
+	sm := NewSessionManager(cfg)
+
+	// A workspace without windows still gets a session carrying its environment
+	ws := sm.sessionWorkspace("project", "/path/to/project", nil)
+	commands := sm.sessionCommands(Server{}, "project", "/path/to/project", ws)
+	if len(commands) != 1 {
+		t.Fatalf("Expected 1 command, got %d", len(commands))
+	}
+
+	expected := []string{"new-session", "-ds", "project", "-c", "/path/to/project", "-e", "A=1", "-e", "B=2"}
+	if !reflect.DeepEqual(commands[0].args, expected) {
+		t.Errorf("args = %v, want %v", commands[0].args, expected)
+	}
+}
+
+func TestSessionCommandsInterpolation(t *testing.T) {
+	cfg := &config.Config{
+		Workspace: []config.WorkspaceConfig{
+			{
+				Directory: "/path/to/project",
+				Name:      "project",
+				Windows:   []config.WindowConfig{{Name: "editor", Command: "nvim {{.Dir}} # {{.Session}} {{.Name}}"}},
+				Env:       map[string]string{"SESSION": "{{.Session}}"},
+			},
+		},
+	}
+	sm := NewSessionManager(cfg)
+
+	repo := &git.Info{Repo: "project", Root: "/path/to/project", Branch: "main"}
+	ws := sm.sessionWorkspace("project/main", "/path/to/project", repo)
+	commands := sm.sessionCommands(Server{}, "project/main", "/path/to/project", ws)
+
+	if got := commands[0].args[len(commands[0].args)-1]; got != "SESSION=project/main" {
+		t.Errorf("Expected env to be rendered, got %q", got)
+	}
+	last := commands[len(commands)-1]
+	if last.args[3] != "nvim /path/to/project # project/main project" {
+		t.Errorf("Expected command to be rendered, got %q", last.args[3])
+	}
+}
+
+func TestSessionCommandsWindowEnv(t *testing.T) {
+	cfg := &config.Config{
+		Workspace: []config.WorkspaceConfig{
+			{
+				Directory: "/path/to/project",
+				Name:      "project",
+				Windows: []config.WindowConfig{
+					{Name: "editor", Env: map[string]string{"EDITOR": "nvim"}},
+					{Name: "aws", Env: map[string]string{"AWS_PROFILE": "staging"}},
+				},
+			},
+		},
+	}
+	sm := NewSessionManager(cfg)
+
+	ws := sm.sessionWorkspace("project", "/path/to/project", nil)
+	commands := sm.sessionCommands(Server{}, "project", "/path/to/project", ws)
+
+	expected := [][]string{
+		{"new-session", "-ds", "project", "-c", "/path/to/project", "-n", "editor"},
+		{"respawn-pane", "-k", "-t", "=project:", "-c", "/path/to/project", "-e", "EDITOR=nvim"},
+		{"neww", "-t", "=project", "-c", "/path/to/project", "-n", "aws", "-e", "AWS_PROFILE=staging"},
+	}
+	if len(commands) != len(expected) {
+		t.Fatalf("Expected %d commands, got %d", len(expected), len(commands))
+	}
+	for i, cmd := range commands {
+		if !reflect.DeepEqual(cmd.args, expected[i]) {
+			t.Errorf("command %d = %v, want %v", i, cmd.args, expected[i])
+		}
+	}
+}
+
+func TestSessionCommandsWindowDirAndFocus(t *testing.T) {
+	cfg := &config.Config{
+		Workspace: []config.WorkspaceConfig{
+			{
+				Directory: "/path/to/monorepo",
+				Name:      "monorepo",
+				Windows: []config.WindowConfig{
+					{Name: "frontend", Dir: "frontend", Focus: true},
+					{Name: "backend", Dir: "services/backend"},
+					{Name: "notes", Dir: "/tmp/notes"},
+					{Name: "root"},
+				},
+			},
+		},
+	}
+	sm := NewSessionManager(cfg)
+
+	ws := sm.sessionWorkspace("monorepo", "/path/to/monorepo", nil)
+	commands := sm.sessionCommands(Server{}, "monorepo", "/path/to/monorepo", ws)
+
+	expected := [][]string{
+		{"new-session", "-ds", "monorepo", "-c", "/path/to/monorepo", "-n", "frontend"},
+		{"respawn-pane", "-k", "-t", "=monorepo:", "-c", "/path/to/monorepo/frontend"},
+		{"neww", "-t", "=monorepo", "-c", "/path/to/monorepo/services/backend", "-n", "backend"},
+		{"neww", "-t", "=monorepo", "-c", "/tmp/notes", "-n", "notes"},
+		{"neww", "-t", "=monorepo", "-c", "/path/to/monorepo", "-n", "root"},
+		// The focused window is three windows before the last one created
+		{"select-window", "-t", "=monorepo:-3"},
+	}
+	if len(commands) != len(expected) {
+		t.Fatalf("Expected %d commands, got %d", len(expected), len(commands))
+	}
+	for i, cmd := range commands {
+		if !reflect.DeepEqual(cmd.args, expected[i]) {
+			t.Errorf("command %d = %v, want %v", i, cmd.args, expected[i])
+		}
+	}
+}
+
+func TestWithEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("REGION=eu-west-1\nPROFILE=dev\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Variables from the workspace config win over the file
+	env := withEnvFile(map[string]string{"PROFILE": "staging"}, ".env", dir)
+	expected := map[string]string{"REGION": "eu-west-1", "PROFILE": "staging"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("withEnvFile() = %v, want %v", env, expected)
+	}
+
+	// A missing file leaves the environment untouched
+	env = withEnvFile(map[string]string{"PROFILE": "staging"}, "missing.env", dir)
+	if !reflect.DeepEqual(env, map[string]string{"PROFILE": "staging"}) {
+		t.Errorf("withEnvFile() with a missing file = %v", env)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	dir := t.TempDir()
+
+	runHooks([]string{"echo $GREETING > out.txt", "exit 1", "echo done >> out.txt"}, dir, map[string]string{"GREETING": "hello"})
+
+	// Hooks run in the workspace directory with its env, and a failing hook does not stop the rest
+	content, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatalf("Expected hooks to write out.txt: %v", err)
+	}
+	if string(content) != "hello\ndone\n" {
+		t.Errorf("out.txt = %q, want %q", content, "hello\ndone\n")
+	}
+}
+
 func TestTmuxRunning(t *testing.T) {
 	// This test just ensures the function works
 	// The actual result depends on whether we're running in tmux
@@ -244,3 +469,186 @@ func TestTmuxRunning(t *testing.T) {
 	// Result should be a boolean
 	_ = result
 }
+
+// fakeSource records the directories added to it
+type fakeSource struct {
+	added []string
+}
+
+func (f *fakeSource) Name() string                    { return "fake" }
+func (f *fakeSource) List() ([]frecency.Entry, error) { return nil, nil }
+func (f *fakeSource) Add(dir string) error {
+	f.added = append(f.added, dir)
+	return nil
+}
+
+func TestRecordVisit(t *testing.T) {
+	t.Run("RecordsAbsolutePath", func(t *testing.T) {
+		source := &fakeSource{}
+		sm := &SessionManager{frecency: source}
+
+		sm.recordVisit("relative/project")
+
+		if len(source.added) != 1 || !filepath.IsAbs(source.added[0]) {
+			t.Errorf("Expected one absolute path to be recorded, got %v", source.added)
+		}
+	})
+
+	t.Run("DisabledByConfig", func(t *testing.T) {
+		record := false
+		sm := NewSessionManager(&config.Config{FrecencyRecord: &record})
+		if sm.frecency != nil {
+			t.Error("Expected no frecency source when recording is disabled")
+		}
+		// Must not panic without a source
+		sm.recordVisit("/tmp")
+	})
+
+	t.Run("DisabledWithZoxide", func(t *testing.T) {
+		useZoxide := false
+		if sm := NewSessionManager(&config.Config{UseZoxide: &useZoxide}); sm.frecency != nil {
+			t.Error("Expected no frecency source when use_zoxide is disabled")
+		}
+	})
+
+	t.Run("EnabledByDefault", func(t *testing.T) {
+		sm := NewSessionManager(&config.Config{})
+		if sm.frecency == nil || sm.frecency.Name() != "zoxide" {
+			t.Error("Expected zoxide to be recorded to by default")
+		}
+	})
+}
+
+func TestCreateSessionWindowTargets(t *testing.T) {
+	server := isolateTmux(t)
+
+	// Window names with `.` or `:` cannot be used in tmux targets, and a session named
+	// after a prefix of another one must not be mistaken for it
+	if err := server.Command("new-session", "-ds", "api-old").Execute(); err != nil {
+		t.Fatalf("new-session error: %v", err)
+	}
+	cfg := &config.Config{
+		Workspace: []config.WorkspaceConfig{{
+			Directory: "/src/api",
+			Name:      "api",
+			Windows: []config.WindowConfig{
+				{Name: "editor"},
+				{Name: "web.v2", Command: "true", Focus: true},
+				{Name: "db:main", Command: "true"},
+			},
+		}},
+	}
+	target := Target{Server: server, Session: "api"}
+	dir := filepath.Join(t.TempDir(), "api")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSessionManager(cfg)
+	if sm.sessionExists(target) {
+		t.Fatal("sessionExists() matched the session api-old")
+	}
+	if err := sm.createSession(target, dir, nil); err != nil {
+		t.Fatalf("createSession() error: %v", err)
+	}
+
+	output, err := server.Command("list-windows", "-t", "=api", "-F", "#{window_name} #{window_active}").Output()
+	if err != nil {
+		t.Fatalf("list-windows error: %v", err)
+	}
+	want := []string{"editor 0", "web.v2 1", "db:main 0"}
+	if got := strings.Split(strings.TrimSpace(string(output)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("windows = %q, want %q", got, want)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	server := isolateTmux(t)
+	target := Target{Server: server, Session: "api"}
+	neighbour := Target{Server: server, Session: "api-old"}
+	stepErr := fmt.Errorf(`window "web": run command: %w`, errors.New("can't find pane"))
+
+	if err := server.Command("new-session", "-ds", neighbour.Session).Execute(); err != nil {
+		t.Fatalf("new-session error: %v", err)
+	}
+
+	t.Run("LeavesOtherSessions", func(t *testing.T) {
+		// tmux did not start api at all, and api-old must not be taken for it
+		NewSessionManager(nil).rollback(target, stepErr)
+		if !NewSessionManager(nil).sessionExists(neighbour) {
+			t.Error("rollback() removed the session api-old")
+		}
+	})
+
+	t.Run("KillsPartialSession", func(t *testing.T) {
+		if err := server.Command("new-session", "-ds", target.Session).Execute(); err != nil {
+			t.Fatalf("new-session error: %v", err)
+		}
+		sm := NewSessionManager(nil)
+		sm.rollback(target, stepErr)
+		if sm.sessionExists(target) {
+			t.Error("the partially created session was kept")
+		}
+		if !sm.sessionExists(neighbour) {
+			t.Error("rollback() removed the session api-old")
+		}
+	})
+
+	t.Run("KeepOnError", func(t *testing.T) {
+		if err := server.Command("new-session", "-ds", target.Session).Execute(); err != nil {
+			t.Fatalf("new-session error: %v", err)
+		}
+		sm := NewSessionManager(nil)
+		sm.SetKeepOnError(true)
+		sm.rollback(target, stepErr)
+		if !sm.sessionExists(target) {
+			t.Error("the partially created session was removed despite keepOnError")
+		}
+	})
+
+	t.Run("KeepsExistingSession", func(t *testing.T) {
+		// The session now exists, so starting it again fails without touching it
+		sm := NewSessionManager(&config.Config{})
+		if err := sm.createSession(target, t.TempDir(), nil); !errors.Is(err, ErrDuplicateSession) {
+			t.Fatalf("createSession() error = %v, want ErrDuplicateSession", err)
+		}
+		if !sm.sessionExists(target) {
+			t.Error("an existing session was removed")
+		}
+	})
+}
+
+func TestRenameSession(t *testing.T) {
+	server := isolateTmux(t)
+	t.Setenv("HOME", t.TempDir())
+
+	sm := NewSessionManager(nil)
+	sm.SetServer(server)
+	for _, name := range []string{"api", "blog"} {
+		if err := server.Command("new-session", "-ds", name).Execute(); err != nil {
+			t.Fatalf("new-session %s error: %v", name, err)
+		}
+	}
+	history.Record("api", 10)
+
+	renamed, err := sm.RenameSession("api", "api v2.0")
+	if err != nil {
+		t.Fatalf("RenameSession() error: %v", err)
+	}
+	if renamed != "api_v2_0" {
+		t.Errorf("RenameSession() = %q, want the sanitized name %q", renamed, "api_v2_0")
+	}
+	if !sm.sessionExists(Target{Server: server, Session: "api_v2_0"}) {
+		t.Error("the session was not renamed")
+	}
+	if entries := history.Load(10); len(entries) != 1 || entries[0] != "api_v2_0" {
+		t.Errorf("history = %v, want the new name", entries)
+	}
+
+	if _, err := sm.RenameSession("api_v2_0", "blog"); !errors.Is(err, ErrDuplicateSession) {
+		t.Errorf("RenameSession() onto an existing session error = %v, want ErrDuplicateSession", err)
+	}
+	if _, err := sm.RenameSession("missing", "other"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RenameSession() of a missing session error = %v, want ErrSessionNotFound", err)
+	}
+}
@@ -0,0 +1,100 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Server selects the tmux server that commands are sent to. The zero value is the
+// default server, the one tmux itself would use.
+type Server struct {
+	Name string `json:"name,omitempty"` // Socket name, as given to `tmux -L`
+	Path string `json:"path,omitempty"` // Socket path, as given to `tmux -S`; takes precedence over Name
+}
+
+// ServerForSocket returns the server listening on socket, as set by the workspace
+// `socket` option: a path when it contains a slash, a socket name otherwise
+func ServerForSocket(socket string) Server {
+	if strings.ContainsRune(socket, '/') {
+		return Server{Path: socket}
+	}
+	return Server{Name: socket}
+}
+
+// IsDefault reports whether s is the default server
+func (s Server) IsDefault() bool {
+	return s == Server{}
+}
+
+// args returns the tmux flags selecting the server
+func (s Server) args() []string {
+	switch {
+	case s.Path != "":
+		return []string{"-S", s.Path}
+	case s.Name != "":
+		return []string{"-L", s.Name}
+	}
+	return nil
+}
+
+// Command creates a TmuxCommand sent to the server
+func (s Server) Command(args ...string) *TmuxCommand {
+	return &TmuxCommand{server: s, args: args}
+}
+
+// String describes the server for messages
+func (s Server) String() string {
+	switch {
+	case s.Path != "":
+		return s.Path
+	case s.Name != "":
+		return s.Name
+	}
+	return "default"
+}
+
+// socketPath returns the path of the server socket, resolving a socket name the way
+// tmux does, or an empty string for the default server
+func (s Server) socketPath() string {
+	switch {
+	case s.Path != "":
+		return s.Path
+	case s.Name != "":
+		dir := os.Getenv("TMUX_TMPDIR")
+		if dir == "" {
+			dir = "/tmp"
+		}
+		return filepath.Join(dir, fmt.Sprintf("tmux-%d", os.Getuid()), s.Name)
+	}
+	return ""
+}
+
+// Inside reports whether tmx runs inside a session of the server, so that it can switch
+// the current client instead of attaching a new one. For the default server this is the
+// case inside any tmux session, as tmux then talks to the server in $TMUX.
+func (s Server) Inside() bool {
+	if s.IsDefault() {
+		return TmuxRunning()
+	}
+	// $TMUX holds the socket path, the server pid and the session index
+	current, _, _ := strings.Cut(os.Getenv("TMUX"), ",")
+	return current != "" && samePath(current, s.socketPath())
+}
+
+// samePath reports whether a and b name the same file, resolving symlinks where possible
+func samePath(a, b string) bool {
+	return resolvePath(a) == resolvePath(b)
+}
+
+// resolvePath returns the absolute path of path with symlinks resolved where possible
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
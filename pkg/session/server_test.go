@@ -0,0 +1,150 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vbrdnk/tmx/pkg/config"
+)
+
+func TestServerForSocket(t *testing.T) {
+	tests := []struct {
+		socket string
+		want   Server
+		args   []string
+	}{
+		{"work", Server{Name: "work"}, []string{"-L", "work"}},
+		{"/tmp/tmux-work", Server{Path: "/tmp/tmux-work"}, []string{"-S", "/tmp/tmux-work"}},
+		{"./work.sock", Server{Path: "./work.sock"}, []string{"-S", "./work.sock"}},
+	}
+
+	for _, tt := range tests {
+		server := ServerForSocket(tt.socket)
+		if server != tt.want {
+			t.Errorf("ServerForSocket(%q) = %+v, want %+v", tt.socket, server, tt.want)
+		}
+		if got := server.args(); !reflect.DeepEqual(got, tt.args) {
+			t.Errorf("ServerForSocket(%q).args() = %q, want %q", tt.socket, got, tt.args)
+		}
+	}
+
+	if args := (Server{}).args(); args != nil {
+		t.Errorf("default server args = %q, want none", args)
+	}
+}
+
+func TestServerInside(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMUX_TMPDIR", tmpDir)
+	named := Server{Name: "work"}
+
+	t.Run("OutsideTmux", func(t *testing.T) {
+		t.Setenv("TMUX", "")
+		os.Unsetenv("TMUX")
+		if (Server{}).Inside() || named.Inside() {
+			t.Error("Inside() = true outside tmux")
+		}
+	})
+
+	t.Run("InsideServer", func(t *testing.T) {
+		t.Setenv("TMUX", named.socketPath()+",1234,0")
+		if !named.Inside() {
+			t.Error("Inside() = false inside a session of the server")
+		}
+		if !(Server{}).Inside() {
+			t.Error("default server Inside() = false inside tmux")
+		}
+	})
+
+	t.Run("InsideOtherServer", func(t *testing.T) {
+		t.Setenv("TMUX", filepath.Join(tmpDir, "other")+",1234,0")
+		if named.Inside() {
+			t.Error("Inside() = true inside a session of another server")
+		}
+	})
+}
+
+func TestWorkspaceSocket(t *testing.T) {
+	cfg := &config.Config{
+		Workspace: []config.WorkspaceConfig{
+			{Directory: "/src/api", Name: "api", Socket: "work"},
+			{Directory: "/src/blog", Name: "blog"},
+		},
+	}
+	sm := NewSessionManager(cfg)
+	sm.SetServer(Server{Name: "personal"})
+
+	if got := sm.sessionTarget("api").Server; got != (Server{Name: "work"}) {
+		t.Errorf("sessionTarget(api).Server = %+v, want the workspace socket", got)
+	}
+	if got := sm.sessionTarget("blog").Server; got != (Server{Name: "personal"}) {
+		t.Errorf("sessionTarget(blog).Server = %+v, want the manager's server", got)
+	}
+
+	target := sm.sessionTarget("api")
+	commands := sm.sessionCommands(target.Server, target.Session, "/src/api", sm.sessionWorkspace(target.Session, "/src/api", nil))
+	if got := commands[0].server; got != (Server{Name: "work"}) {
+		t.Errorf("session commands sent to %+v, want the workspace socket", got)
+	}
+}
+
+func TestWorkspaceSocketTemplatedName(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := filepath.Join(t.TempDir(), "api")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", dir, "init", "-q", "-b", "main").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+
+	cfg := &config.Config{
+		Workspace: []config.WorkspaceConfig{{Directory: dir, Name: "api-{{.GitBranch}}", Socket: "work"}},
+	}
+	sm := NewSessionManager(cfg)
+
+	// The session opened in the workspace directory is found again by its rendered name
+	name := sm.determineSessionName(dir, nil)
+	if name != "api-main" {
+		t.Fatalf("determineSessionName() = %q, want %q", name, "api-main")
+	}
+	if got := sm.sessionTarget(name).Server; got != (Server{Name: "work"}) {
+		t.Errorf("sessionTarget(%s).Server = %+v, want the workspace socket", name, got)
+	}
+	if got := sm.SessionWorkspace(name); got != "api-{{.GitBranch}}" {
+		t.Errorf("SessionWorkspace(%s) = %q, want the templated workspace", name, got)
+	}
+}
+
+func TestEnsureSessionServer(t *testing.T) {
+	server := isolateTmux(t)
+	t.Setenv("HOME", t.TempDir())
+
+	record := false
+	cfg := &config.Config{
+		FrecencyRecord: &record,
+		Workspace:      []config.WorkspaceConfig{{Directory: "/src/api", Name: "api", Socket: server.Name}},
+	}
+	sm := NewSessionManager(cfg)
+	sm.SetServer(Server{Name: "tmx-test-other"})
+	t.Cleanup(func() { sm.Server().Command("kill-server").Execute() }) //nolint:errcheck
+
+	target, err := sm.EnsureSession(filepath.Join(t.TempDir(), "api"), false)
+	if err != nil {
+		t.Fatalf("EnsureSession() error: %v", err)
+	}
+	if target != (Target{Server: server, Session: "api"}) {
+		t.Errorf("EnsureSession() = %+v, want api on the workspace server", target)
+	}
+	if !sm.sessionExists(target) {
+		t.Error("session was not created on the workspace server")
+	}
+	if sm.sessionExists(Target{Server: sm.Server(), Session: "api"}) {
+		t.Error("session was created on the manager's server")
+	}
+}
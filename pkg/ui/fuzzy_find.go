@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -14,6 +15,44 @@ import (
 var ErrNoSelection = errors.New("nothing selected")
 
 func FuzzyFind(input []byte) (string, error) {
+	return runFzf(func(stdin io.WriteCloser) error {
+		// Write the inpuit data to fzf
+		if _, err := stdin.Write(input); err != nil {
+			return fmt.Errorf("failed to write to fzf stdin: %v", err)
+		}
+
+		// Close the stdin pipe to signal EOF
+		if err := stdin.Close(); err != nil {
+			return fmt.Errorf("failed to close fzf stdin: %v", err)
+		}
+		return nil
+	})
+}
+
+// FuzzyFindStream starts fzf right away and feeds it lines as they arrive on input,
+// so the picker is usable before the list is complete. It returns once the user has
+// made a selection, even if input is still open; the caller is expected to stop its
+// producers at that point.
+func FuzzyFindStream(input <-chan string) (string, error) {
+	return runFzf(func(stdin io.WriteCloser) error {
+		go func() {
+			for line := range input {
+				if _, err := io.WriteString(stdin, line+"\n"); err != nil {
+					// fzf has exited; keep draining so producers never block
+					for range input {
+					}
+					return
+				}
+			}
+			// Close the stdin pipe to signal EOF once the list is complete
+			stdin.Close()
+		}()
+		return nil
+	})
+}
+
+// runFzf starts fzf, lets feed write its input and waits for the user's selection
+func runFzf(feed func(stdin io.WriteCloser) error) (string, error) {
 	var fzfCmd *exec.Cmd
 
 	// Then run fzf with the find output as input
@@ -43,14 +82,8 @@ func FuzzyFind(input []byte) (string, error) {
 		return "", fmt.Errorf("failed to start fzf: %v", err)
 	}
 
-	// Write the inpuit data to fzf
-	if _, err := fzfStdin.Write(input); err != nil {
-		return "", fmt.Errorf("failed to write to fzf stdin: %v", err)
-	}
-
-	// Close the stdin pipe to signal EOF
-	if err := fzfStdin.Close(); err != nil {
-		return "", fmt.Errorf("failed to close fzf stdin: %v", err)
+	if err := feed(fzfStdin); err != nil {
+		return "", err
 	}
 
 	// Wait for fzf to complete